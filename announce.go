@@ -0,0 +1,326 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/glvd/seed/model"
+	"github.com/ipfs/go-cid"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+// DefaultAnnounceTopic ...
+const DefaultAnnounceTopic = "/glvd/seed/announce"
+
+// DefaultAnnounceMaxAge announcements older than this are dropped
+const DefaultAnnounceMaxAge = 24 * time.Hour
+
+// AnnounceKind ...
+type AnnounceKind string
+
+// AnnounceKindUnfinished ...
+const AnnounceKindUnfinished AnnounceKind = "unfinished"
+
+// AnnounceKindVideo ...
+const AnnounceKindVideo AnnounceKind = "video"
+
+// Announcement new or updated content announced on the topic
+type Announcement struct {
+	Peer     string       `json:"peer"`
+	Kind     AnnounceKind `json:"kind"`
+	Hash     string       `json:"hash"`
+	Type     model.Type   `json:"type"`
+	Relate   string       `json:"relate"`
+	Checksum string       `json:"checksum"`
+	Time     int64        `json:"time"`
+}
+
+// Announcer publish the local content and listen the announcements of the other seed nodes
+type Announcer struct {
+	*Thread
+	Topic    string
+	Pin      bool         //enqueue pins of the announced content
+	PinTypes []model.Type //only pin the types,empty to pin all
+	Peers    []string     //only accept the peers,empty to accept all
+	MaxAge   time.Duration
+	peerID   string
+	seen     map[string]time.Time //the received time of peer and hash,expired after the max age
+	cb       chan *Announcement
+}
+
+// AnnouncerArgs ...
+type AnnouncerArgs func(a *Announcer)
+
+// AnnouncerTopicArg ...
+func AnnouncerTopicArg(topic string) AnnouncerArgs {
+	return func(a *Announcer) {
+		a.Topic = topic
+	}
+}
+
+// AnnouncerPinArg enqueue pins of the announced types
+func AnnouncerPinArg(types ...model.Type) AnnouncerArgs {
+	return func(a *Announcer) {
+		a.Pin = true
+		a.PinTypes = types
+	}
+}
+
+// AnnouncerPeerArg only accept the announcements from peers
+func AnnouncerPeerArg(peers ...string) AnnouncerArgs {
+	return func(a *Announcer) {
+		a.Peers = append(a.Peers, peers...)
+	}
+}
+
+// NewAnnouncer ...
+func NewAnnouncer(args ...AnnouncerArgs) *Announcer {
+	a := &Announcer{
+		Thread: NewThread(),
+		Topic:  DefaultAnnounceTopic,
+		MaxAge: DefaultAnnounceMaxAge,
+		seen:   make(map[string]time.Time),
+		cb:     make(chan *Announcement, 10),
+	}
+	for _, argFn := range args {
+		argFn(a)
+	}
+	return a
+}
+
+// Option ...
+func (a *Announcer) Option(s Seeder) {
+	s.SetBaseThread(StepperAnnounce, a)
+}
+
+// Push ...
+func (a *Announcer) Push(v interface{}) error {
+	if ann, b := v.(*Announcement); b {
+		a.cb <- ann
+		return nil
+	}
+	return errors.New("not announcement")
+}
+
+// Run ...
+func (a *Announcer) Run(ctx context.Context) {
+	log.Info("announcer running")
+	api, b := a.GetThread(StepperAPI).(*API)
+	if !b {
+		log.Error("announcer need the api thread")
+		a.Finished()
+		return
+	}
+	node := api.Node("")
+	pid := new(PeerID)
+	e := node.API().Request("id").Exec(ctx, pid)
+	if e != nil {
+		log.Error(e)
+	}
+	a.peerID = pid.ID
+	go a.listen(ctx, node.API())
+AnnounceEnd:
+	for {
+		select {
+		case <-ctx.Done():
+			break AnnounceEnd
+		case ann := <-a.cb:
+			if ann == nil {
+				break AnnounceEnd
+			}
+			a.SetState(StateRunning)
+			ann.Peer = a.peerID
+			e := a.publish(ctx, node.API(), ann)
+			if e != nil {
+				log.With("hash", ann.Hash).Error(e)
+			}
+		case <-time.After(TimeOutLimit):
+			a.SetState(StateWaiting)
+		}
+	}
+	a.Finished()
+}
+
+func (a *Announcer) publish(ctx context.Context, api *httpapi.HttpApi, ann *Announcement) error {
+	data, e := json.Marshal(ann)
+	if e != nil {
+		return e
+	}
+	log.With("hash", ann.Hash, "type", ann.Type, "relate", ann.Relate).Info("announce")
+	return api.PubSub().Publish(ctx, a.Topic, data)
+}
+
+func (a *Announcer) listen(ctx context.Context, api *httpapi.HttpApi) {
+	for {
+		sub, e := api.PubSub().Subscribe(ctx, a.Topic)
+		if e == nil {
+			for {
+				msg, e := sub.Next(ctx)
+				if e != nil {
+					log.Error(e)
+					break
+				}
+				ann := new(Announcement)
+				e = json.Unmarshal(msg.Data(), ann)
+				if e != nil {
+					log.With("from", msg.From().Pretty()).Error(e)
+					continue
+				}
+				if e := a.Validate(msg.From().Pretty(), ann); e != nil {
+					log.With("from", msg.From().Pretty(), "hash", ann.Hash).Info(e.Error())
+					continue
+				}
+				a.receive(ann)
+			}
+			_ = sub.Close()
+		} else {
+			log.Error(e)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(TimeOutLimit):
+		}
+	}
+}
+
+// Validate check the announcement received from peer
+func (a *Announcer) Validate(from string, ann *Announcement) error {
+	if ann.Peer != from {
+		return errors.New("announcement peer mismatch")
+	}
+	if from == a.peerID {
+		return errors.New("announcement from self")
+	}
+	if len(a.Peers) > 0 && !containsString(a.Peers, from) {
+		return errors.New("announcement from untrusted peer")
+	}
+	if _, e := cid.Decode(ann.Hash); e != nil {
+		return e
+	}
+	switch ann.Type {
+	case model.TypeVideo, model.TypeSlice, model.TypePoster, model.TypeThumb, model.TypeCaption, model.TypePreview:
+	default:
+		return errors.New("unknown announcement type")
+	}
+	if a.MaxAge > 0 && time.Since(time.Unix(ann.Time, 0)) > a.MaxAge {
+		return errors.New("announcement expired")
+	}
+	return nil
+}
+
+// receive record the pin of the announced peer and enqueue the pin by policy
+func (a *Announcer) receive(ann *Announcement) {
+	if !a.remember(ann.Peer+ann.Hash, time.Now()) {
+		return
+	}
+	log.With("peer", ann.Peer, "hash", ann.Hash, "type", ann.Type, "relate", ann.Relate).Info("announced")
+	pin := &model.Pin{
+		PinHash: ann.Hash,
+		PeerID:  ann.Peer,
+	}
+	e := a.PushTo(DatabaseCallback(pin, func(database *Database, eng *xorm.Engine, v interface{}) (e error) {
+		return model.UpdatePinVideoID(eng.Where(""), v.(*model.Pin))
+	}))
+	if e != nil {
+		log.Error(e)
+	}
+	if !a.Pin || (len(a.PinTypes) > 0 && !containsType(a.PinTypes, ann.Type)) {
+		return
+	}
+	e = a.PushTo(APICallback(ann, func(api *API, ipapi *httpapi.HttpApi, v interface{}) (e error) {
+		ann := v.(*Announcement)
+		log.With("hash", ann.Hash, "type", ann.Type, "relate", ann.Relate).Info("announced pinning")
+		return PinAdd(api, ipapi, ann.Hash)
+	}))
+	if e != nil {
+		log.Error(e)
+	}
+}
+
+// remember record the key received at now,return false if it was received within the max age
+func (a *Announcer) remember(key string, now time.Time) bool {
+	maxAge := a.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultAnnounceMaxAge
+	}
+	for k, t := range a.seen {
+		if now.Sub(t) > maxAge {
+			delete(a.seen, k)
+		}
+	}
+	if _, b := a.seen[key]; b {
+		return false
+	}
+	a.seen[key] = now
+	return true
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(ts []model.Type, t model.Type) bool {
+	for _, v := range ts {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnounceUnfinished announce the unfinished if the announcer thread is registered
+func AnnounceUnfinished(s Seeder, u *model.Unfinished) {
+	if u.Hash == "" {
+		return
+	}
+	announce(s, &Announcement{
+		Kind:     AnnounceKindUnfinished,
+		Hash:     u.Hash,
+		Type:     u.Type,
+		Relate:   u.Relate,
+		Checksum: u.Checksum,
+		Time:     time.Now().Unix(),
+	})
+}
+
+// AnnounceVideo announce the hashes of video if the announcer thread is registered
+func AnnounceVideo(s Seeder, video *model.Video) {
+	hashes := map[model.Type]string{
+		model.TypeSlice:   video.M3U8Hash,
+		model.TypeVideo:   video.SourceHash,
+		model.TypePoster:  video.PosterHash,
+		model.TypeThumb:   video.ThumbHash,
+		model.TypePreview: video.PreviewHash,
+	}
+	for t, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		announce(s, &Announcement{
+			Kind:   AnnounceKindVideo,
+			Hash:   hash,
+			Type:   t,
+			Relate: video.Bangumi,
+			Time:   time.Now().Unix(),
+		})
+	}
+}
+
+func announce(s Seeder, ann *Announcement) {
+	if s == nil || !s.HasThread(StepperAnnounce) {
+		return
+	}
+	e := s.PushTo(StepperAnnounce, ann)
+	if e != nil {
+		log.With("hash", ann.Hash).Error(e)
+	}
+}
@@ -0,0 +1,29 @@
+package seed
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAnnouncer_remember ...
+func TestAnnouncer_remember(t *testing.T) {
+	a := NewAnnouncer()
+	now := time.Now()
+	if !a.remember("peer1hash1", now) {
+		t.Error("first receive")
+	}
+	if a.remember("peer1hash1", now.Add(time.Hour)) {
+		t.Error("duplicate within max age")
+	}
+	a.remember("peer2hash2", now.Add(time.Hour))
+	later := now.Add(a.MaxAge + time.Minute)
+	if !a.remember("peer3hash3", later) {
+		t.Error("new receive")
+	}
+	if len(a.seen) != 2 {
+		t.Errorf("expired entry kept:%d", len(a.seen))
+	}
+	if !a.remember("peer1hash1", later) {
+		t.Error("receive after max age")
+	}
+}
@@ -0,0 +1,102 @@
+package seed_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const announcePeer = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+const announceHash = "QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB"
+
+// TestAnnouncer ...
+func TestAnnouncer(t *testing.T) {
+	pid, e := peer.IDB58Decode(announcePeer)
+	if e != nil {
+		t.Fatal(e)
+	}
+	data, e := json.Marshal(&seed.Announcement{
+		Peer: announcePeer,
+		Kind: seed.AnnounceKindUnfinished,
+		Hash: announceHash,
+		Type: model.TypeSlice,
+		Time: time.Now().Unix(),
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	msg, e := json.Marshal(map[string]interface{}{
+		"from": []byte(pid),
+		"data": data,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	pinned := make(chan string, 1)
+	srv, addr := stubNode(t, "QmSelf", 0)
+	defer srv.Close()
+	mux := srv.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/api/v0/pubsub/sub", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, string(msg))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/api/v0/pin/add", func(w http.ResponseWriter, r *http.Request) {
+		pinned <- r.URL.Query().Get("arg")
+		_, _ = fmt.Fprintf(w, `{"Pins":["%s"]}`, r.URL.Query().Get("arg"))
+	})
+
+	api := seed.NewAPI(addr, seed.APIHealthCheckArg(0))
+	announcer := seed.NewAnnouncer(seed.AnnouncerPinArg(model.TypeSlice))
+	s := seed.NewSeed(api, announcer)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api.BeforeRun(s)
+	announcer.BeforeRun(s)
+	go api.Run(ctx)
+	go announcer.Run(ctx)
+
+	select {
+	case hash := <-pinned:
+		if hash != announceHash {
+			t.Error(hash)
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("announcement not pinned")
+	}
+}
+
+// TestAnnouncer_Validate ...
+func TestAnnouncer_Validate(t *testing.T) {
+	announcer := seed.NewAnnouncer(seed.AnnouncerPeerArg(announcePeer))
+	ann := &seed.Announcement{
+		Peer: announcePeer,
+		Hash: announceHash,
+		Type: model.TypeVideo,
+		Time: time.Now().Unix(),
+	}
+	if e := announcer.Validate(announcePeer, ann); e != nil {
+		t.Error(e)
+	}
+	if e := announcer.Validate("QmOther", ann); e == nil {
+		t.Error("peer mismatch")
+	}
+	ann.Hash = "not a cid"
+	if e := announcer.Validate(announcePeer, ann); e == nil {
+		t.Error("invalid hash")
+	}
+	ann.Hash = announceHash
+	ann.Time = time.Now().Add(-48 * time.Hour).Unix()
+	if e := announcer.Validate(announcePeer, ann); e == nil {
+		t.Error("expired")
+	}
+}
@@ -4,22 +4,50 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/glvd/seed/model"
 	files "github.com/ipfs/go-ipfs-files"
 	httpapi "github.com/ipfs/go-ipfs-http-client"
+	iface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
-	"github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/xormsharp/xorm"
 	"go.uber.org/atomic"
 )
 
 // API ...
 type API struct {
 	*Thread
-	failed *atomic.Bool
-	api    *httpapi.HttpApi
-	cb     chan APICaller
+	Policy      APIPolicy
+	HealthCheck time.Duration
+	//ProgressInterval min interval of the progress events sent to the sink
+	ProgressInterval time.Duration
+	progress         ProgressSink
+	failed           *atomic.Bool
+	nodes            []*APINode
+	index            *atomic.Uint32
+	current          *APINode
+	addOptions       map[model.Type]*model.AddOption
+	cb               chan APICaller
+}
+
+// SetAddOption set the unixfs add option of the type
+func (api *API) SetAddOption(t model.Type, opt *model.AddOption) {
+	api.addOptions[t] = opt
+}
+
+// AddOption get the unixfs add option of the type
+func (api *API) AddOption(t model.Type) *model.AddOption {
+	return api.addOptions[t]
+}
+
+// SetProgress set the sink receiving the add and pin progress
+func (api *API) SetProgress(sink ProgressSink) {
+	api.progress = sink
 }
 
 // Failed ...
@@ -49,21 +77,26 @@ func (api *API) Push(v interface{}) error {
 }
 
 // NewAPI ...
-func NewAPI(path string) *API {
+func NewAPI(path string, args ...APIArgs) *API {
 	a := new(API)
-	var e error
-	addr, e := multiaddr.NewMultiaddr(path)
-	if e != nil {
-		panic(e)
-	}
-	a.api, e = httpapi.NewApi(addr)
+	node, e := NewAPINode(path)
 	if e != nil {
 		panic(e)
 	}
+	a.nodes = []*APINode{node}
+	a.current = node
+	a.Policy = APIPolicyRoundRobin
+	a.HealthCheck = DefaultHealthCheck
+	a.ProgressInterval = DefaultProgressInterval
+	a.index = atomic.NewUint32(0)
 	a.failed = atomic.NewBool(false)
+	a.addOptions = make(map[model.Type]*model.AddOption)
 	a.cb = make(chan APICaller, 10)
 	a.Thread = NewThread()
 
+	for _, argFn := range args {
+		argFn(a)
+	}
 	return a
 }
 
@@ -80,6 +113,7 @@ func (api *API) push(cb interface{}) (e error) {
 func (api *API) Run(ctx context.Context) {
 	log.Info("api running")
 	var e error
+	go api.healthCheck(ctx)
 APIEnd:
 	for {
 		select {
@@ -90,7 +124,11 @@ APIEnd:
 				break APIEnd
 			}
 			api.SetState(StateRunning)
-			e = c.Call(api, api.api)
+			//the callers keep the node of the last add,the node is selected by the policy on the add
+			if !api.current.Healthy() {
+				api.current = api.Node("")
+			}
+			e = c.Call(api, api.current.api)
 			if e != nil {
 				log.Error(e)
 			}
@@ -132,45 +170,273 @@ func (a *apiCall) Call(api *API, api2 *httpapi.HttpApi) error {
 
 var _ APICaller = &apiCall{}
 
+// AddArgs ...
+type AddArgs func(add *addSetting)
+
+type addSetting struct {
+	key        string
+	typ        model.Type
+	unfinished *model.Unfinished
+	links      bool
+}
+
+// AddKeyArg route the add with key under the sticky policy
+func AddKeyArg(key string) AddArgs {
+	return func(add *addSetting) {
+		add.key = key
+	}
+}
+
+// AddTypeArg add with the add option of the type
+func AddTypeArg(t model.Type) AddArgs {
+	return func(add *addSetting) {
+		add.typ = t
+	}
+}
+
+// AddUnfinishedArg route the add by the unfinished relate and type,record the node and add option on it
+func AddUnfinishedArg(u *model.Unfinished) AddArgs {
+	return func(add *addSetting) {
+		add.unfinished = u
+		if add.key == "" {
+			add.key = strings.Split(u.Relate, "@")[0]
+		}
+		if add.typ == "" {
+			add.typ = u.Type
+		}
+	}
+}
+
+// unixfsAddOption convert the add option to the unixfs settings
+func unixfsAddOption(opt *model.AddOption) options.UnixfsAddOption {
+	return func(settings *options.UnixfsAddSettings) error {
+		settings.Pin = true
+		if opt == nil {
+			return nil
+		}
+		settings.CidVersion = opt.CidVersion
+		//the raw leaves default of the cid version is kept if not set
+		if opt.RawLeaves != nil {
+			settings.RawLeaves = *opt.RawLeaves
+			settings.RawLeavesSet = true
+		}
+		if opt.Chunker != "" {
+			settings.Chunker = opt.Chunker
+		}
+		if opt.Hash != "" {
+			code, b := mh.Names[opt.Hash]
+			if !b {
+				return errors.New("unknown hash function: " + opt.Hash)
+			}
+			settings.MhType = code
+		}
+		if opt.Trickle {
+			settings.Layout = options.TrickleLayout
+		}
+		if opt.OnlyHash {
+			settings.OnlyHash = true
+			settings.Pin = false
+		}
+		if opt.NoCopy {
+			//filestore only works with raw leaves
+			settings.NoCopy = true
+			settings.RawLeaves = true
+			settings.RawLeavesSet = true
+		}
+		return nil
+	}
+}
+
+// nodeError the error returned by the node,the add fails over to the other nodes on it only
+type nodeError struct {
+	error
+}
+
+// add run fn on the selected node,failover to the other healthy nodes on the node error,
+// the local errors are returned directly
+func (api *API) add(fn func(node *APINode, opt *model.AddOption) (path.Resolved, error), args ...AddArgs) (resolved path.Resolved, e error) {
+	add := new(addSetting)
+	for _, argFn := range args {
+		argFn(add)
+	}
+	opt := api.AddOption(add.typ)
+	if _, _, e = options.UnixfsAddOptions(unixfsAddOption(opt)); e != nil {
+		return nil, e
+	}
+	//the node is selected once for the add,the following steps of the caller stay on it
+	node := api.Node(add.key)
+	for i := 0; i < len(api.nodes); i++ {
+		resolved, e = fn(node, opt)
+		if e == nil {
+			api.current = node
+			if add.unfinished != nil {
+				add.unfinished.Node = node.Addr
+				if opt != nil {
+					add.unfinished.AddOption = opt.Clone()
+				}
+				if add.links {
+					obj, e := LsObject(api.Context(), node.api, resolved)
+					if e != nil {
+						log.With("hash", resolved.Cid().String()).Error(e)
+					} else {
+						add.unfinished.Object = obj
+					}
+				}
+			}
+			return resolved, nil
+		}
+		ne, b := e.(*nodeError)
+		if !b {
+			return nil, e
+		}
+		e = ne.error
+		log.With("node", node.Addr).Error(e)
+		node.setHealthy(false)
+		next := api.Node(add.key)
+		if !next.Healthy() {
+			break
+		}
+		node = next
+	}
+	return nil, e
+}
+
 // AddFile ...
-func AddFile(api *API, filename string) (path.Resolved, error) {
-	file, e := os.Open(filename)
+func AddFile(api *API, filename string, args ...AddArgs) (path.Resolved, error) {
+	return api.add(func(node *APINode, opt *model.AddOption) (path.Resolved, error) {
+		abs, e := filepath.Abs(filename)
+		if e != nil {
+			return nil, e
+		}
+		file, e := os.Open(abs)
+		if e != nil {
+			return nil, e
+		}
+		defer file.Close()
+		stat, e := file.Stat()
+		if e != nil {
+			return nil, e
+		}
+		//the abs path is sent for the filestore(nocopy) add
+		rf, e := files.NewReaderPathFile(abs, file, stat)
+		if e != nil {
+			return nil, e
+		}
+		progress, wait := api.addProgress(node, abs, stat.Size())
+		resolved, e := node.api.Unixfs().Add(api.Context(), rf, unixfsAddOption(opt), progress)
+		wait()
+		if e != nil {
+			return nil, &nodeError{e}
+		}
+		if opt != nil && opt.NoCopy {
+			store := &model.FileStore{
+				Hash:    model.PinHash(resolved),
+				Path:    abs,
+				Size:    stat.Size(),
+				ModTime: stat.ModTime(),
+				Node:    node.Addr,
+			}
+			e = api.PushTo(DatabaseCallback(store, func(database *Database, eng *xorm.Engine, v interface{}) (e error) {
+				return model.AddOrUpdateFileStore(eng.Where(""), v.(*model.FileStore))
+			}))
+			if e != nil {
+				log.With("hash", store.Hash, "path", abs).Error(e)
+			}
+		} else {
+			node.size.Add(uint64(stat.Size()))
+		}
+		return resolved, nil
+	}, args...)
+}
+
+// AddDir add the dir,the links of the dir are recorded on the unfinished
+func AddDir(api *API, dir string, args ...AddArgs) (path.Resolved, error) {
+	args = append(args, func(add *addSetting) {
+		add.links = true
+	})
+	return api.add(func(node *APINode, opt *model.AddOption) (path.Resolved, error) {
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		sf, err := files.NewSerialFile(dir, false, stat)
+		if err != nil {
+			return nil, err
+		}
+		//不加目录
+		//slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry(filepath.Base(dir), sf)})
+		//reader := files.NewMultiFileReader(slf, true)
+		size, err := sf.Size()
+		if err != nil {
+			return nil, err
+		}
+		progress, wait := api.addProgress(node, dir, size)
+		resolved, e := node.api.Unixfs().Add(api.Context(), sf, unixfsAddOption(opt), progress)
+		wait()
+		if e != nil {
+			return nil, &nodeError{e}
+		}
+		node.size.Add(uint64(size))
+		return resolved, nil
+	}, args...)
+}
+
+// LsObject list the links of the dir,the dir itself is the link of the object,
+// the size of the dir is the cumulative size including the sub dirs
+func LsObject(ctx context.Context, api *httpapi.HttpApi, p path.Path) (*model.VideoObject, error) {
+	entries, e := api.Unixfs().Ls(ctx, p, options.Unixfs.ResolveChildren(true))
 	if e != nil {
 		return nil, e
 	}
-	resolved, e := api.api.Unixfs().Add(api.Context(), files.NewReaderFile(file),
-		func(settings *options.UnixfsAddSettings) error {
-			settings.Pin = true
-			return nil
+	obj := new(model.VideoObject)
+	for entry := range entries {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		obj.Links = append(obj.Links, &model.VideoLink{
+			Hash: entry.Cid.String(),
+			Name: entry.Name,
+			Size: entry.Size,
+			Type: int(entry.Type),
 		})
-	return resolved, e
+	}
+	hash := strings.TrimPrefix(p.String(), "/ipfs/")
+	if r, b := p.(path.Resolved); b {
+		hash = r.Cid().String()
+	}
+	size, e := CumulativeSize(ctx, api, p.String())
+	if e != nil {
+		return nil, e
+	}
+	obj.Link = &model.VideoLink{
+		Hash: hash,
+		Size: size,
+		Type: int(iface.TDirectory),
+	}
+	return obj, nil
 }
 
-// AddDir ...
-func AddDir(api *API, dir string) (path.Resolved, error) {
-	stat, err := os.Lstat(dir)
-	if err != nil {
-		return nil, err
+// CumulativeSize the cumulative dag size of the path
+func CumulativeSize(ctx context.Context, api *httpapi.HttpApi, p string) (uint64, error) {
+	var stat struct {
+		CumulativeSize uint64
 	}
-
-	sf, err := files.NewSerialFile(dir, false, stat)
-	if err != nil {
-		return nil, err
+	e := api.Request("object/stat", p).Exec(ctx, &stat)
+	if e != nil {
+		return 0, e
 	}
-	//不加目录
-	//slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry(filepath.Base(dir), sf)})
-	//reader := files.NewMultiFileReader(slf, true)
-	resolved, e := api.api.Unixfs().Add(api.Context(), sf,
-		func(settings *options.UnixfsAddSettings) error {
-			settings.Pin = true
-			return nil
-		})
-	return resolved, e
+	return stat.CumulativeSize, nil
 }
 
+// MyID ...
 func MyID(api *API) (*PeerID, error) {
 	pid := new(PeerID)
-	e := api.api.Request("id").Exec(api.Context(), pid)
+	node := api.current
+	if node == nil {
+		node = api.Node("")
+	}
+	e := node.api.Request("id").Exec(api.Context(), pid)
 	if e != nil {
 		return nil, e
 	}
@@ -0,0 +1,250 @@
+package seed
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/multiformats/go-multiaddr"
+	"go.uber.org/atomic"
+)
+
+// APIPolicy ...
+type APIPolicy string
+
+// APIPolicyRoundRobin route to the next healthy node
+const APIPolicyRoundRobin APIPolicy = "round_robin"
+
+// APIPolicyLeastLoaded route to the healthy node with the smallest repo
+const APIPolicyLeastLoaded APIPolicy = "least_loaded"
+
+// APIPolicySticky route the same key(bangumi) to the same node
+const APIPolicySticky APIPolicy = "sticky"
+
+// DefaultHealthCheck ...
+const DefaultHealthCheck = 30 * time.Second
+
+// APINode ...
+type APINode struct {
+	Addr    string
+	api     *httpapi.HttpApi
+	id      *atomic.String
+	healthy *atomic.Bool
+	size    *atomic.Uint64
+}
+
+// RepoStat ...
+type RepoStat struct {
+	RepoSize   uint64 `json:"RepoSize"`
+	StorageMax uint64 `json:"StorageMax"`
+	NumObjects uint64 `json:"NumObjects"`
+}
+
+// NewAPINode ...
+func NewAPINode(path string) (*APINode, error) {
+	addr, e := multiaddr.NewMultiaddr(path)
+	if e != nil {
+		return nil, e
+	}
+	api, e := httpapi.NewApi(addr)
+	if e != nil {
+		return nil, e
+	}
+	return &APINode{
+		Addr:    path,
+		api:     api,
+		id:      atomic.NewString(""),
+		healthy: atomic.NewBool(true),
+		size:    atomic.NewUint64(0),
+	}, nil
+}
+
+// API ...
+func (n *APINode) API() *httpapi.HttpApi {
+	return n.api
+}
+
+// Healthy ...
+func (n *APINode) Healthy() bool {
+	return n.healthy.Load()
+}
+
+// ID peer id of the node,returns the addr before the first health check,
+// the addr is recorded as the node of the adds and pins
+func (n *APINode) ID() string {
+	if id := n.id.Load(); id != "" {
+		return id
+	}
+	return n.Addr
+}
+
+// Size repo size reported on health check with the bytes added since
+func (n *APINode) Size() uint64 {
+	return n.size.Load()
+}
+
+// Check ...
+func (n *APINode) Check(ctx context.Context) error {
+	pid := new(PeerID)
+	e := n.api.Request("id").Exec(ctx, pid)
+	if e != nil {
+		n.setHealthy(false)
+		return e
+	}
+	n.id.Store(pid.ID)
+	stat := new(RepoStat)
+	e = n.api.Request("repo/stat").Option("size-only", true).Exec(ctx, stat)
+	if e != nil {
+		log.With("node", n.Addr).Error(e)
+	} else {
+		n.size.Store(stat.RepoSize)
+	}
+	n.setHealthy(true)
+	return nil
+}
+
+func (n *APINode) setHealthy(healthy bool) {
+	if n.healthy.Swap(healthy) != healthy {
+		log.With("node", n.Addr, "healthy", healthy).Info("node status")
+	}
+}
+
+// Nodes ...
+func (api *API) Nodes() []*APINode {
+	return api.nodes
+}
+
+// nodeOf the node of the http api,nil if not found
+func (api *API) nodeOf(ipapi *httpapi.HttpApi) *APINode {
+	for _, n := range api.nodes {
+		if n.api == ipapi {
+			return n
+		}
+	}
+	return nil
+}
+
+// Node select a healthy node by policy,key is used by the sticky policy
+func (api *API) Node(key string) *APINode {
+	size := len(api.nodes)
+	switch api.Policy {
+	case APIPolicySticky:
+		if key != "" {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key))
+			return api.healthyFrom(int(h.Sum32() % uint32(size)))
+		}
+		return api.healthyFrom(0)
+	case APIPolicyLeastLoaded:
+		var node *APINode
+		for _, n := range api.nodes {
+			if !n.Healthy() {
+				continue
+			}
+			if node == nil || n.Size() < node.Size() {
+				node = n
+			}
+		}
+		if node != nil {
+			return node
+		}
+		return api.nodes[0]
+	default:
+		return api.healthyFrom(int(api.index.Inc() % uint32(size)))
+	}
+}
+
+// healthyFrom find the first healthy node start from idx
+func (api *API) healthyFrom(idx int) *APINode {
+	size := len(api.nodes)
+	for i := 0; i < size; i++ {
+		if n := api.nodes[(idx+i)%size]; n.Healthy() {
+			return n
+		}
+	}
+	return api.nodes[idx%size]
+}
+
+// CheckHealth check all nodes once
+func (api *API) CheckHealth(ctx context.Context) {
+	healthy := 0
+	for _, n := range api.nodes {
+		c, cancel := context.WithTimeout(ctx, TimeOutLimit)
+		e := n.Check(c)
+		cancel()
+		if e != nil {
+			log.With("node", n.Addr).Error(e)
+			continue
+		}
+		healthy++
+	}
+	if healthy > 0 {
+		api.SetFailed(false)
+	}
+}
+
+func (api *API) healthCheck(ctx context.Context) {
+	if api.HealthCheck <= 0 {
+		return
+	}
+	api.CheckHealth(ctx)
+	ticker := time.NewTicker(api.HealthCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			api.CheckHealth(ctx)
+		}
+	}
+}
+
+// APIArgs ...
+type APIArgs func(api *API)
+
+// APINodeArg add more nodes to the api
+func APINodeArg(paths ...string) APIArgs {
+	return func(api *API) {
+		for _, path := range paths {
+			node, e := NewAPINode(path)
+			if e != nil {
+				panic(e)
+			}
+			api.nodes = append(api.nodes, node)
+		}
+	}
+}
+
+// APIPolicyArg ...
+func APIPolicyArg(policy APIPolicy) APIArgs {
+	return func(api *API) {
+		api.Policy = policy
+	}
+}
+
+// APIAddOptionArg set the unixfs add option of the type
+func APIAddOptionArg(t model.Type, opt *model.AddOption) APIArgs {
+	return func(api *API) {
+		api.SetAddOption(t, opt)
+	}
+}
+
+// APIProgressArg set the sink receiving the add and pin progress,the events are sent no more than once in interval
+func APIProgressArg(sink ProgressSink, interval time.Duration) APIArgs {
+	return func(api *API) {
+		api.SetProgress(sink)
+		if interval > 0 {
+			api.ProgressInterval = interval
+		}
+	}
+}
+
+// APIHealthCheckArg set the health check interval,zero to disable
+func APIHealthCheckArg(d time.Duration) APIArgs {
+	return func(api *API) {
+		api.HealthCheck = d
+	}
+}
@@ -2,12 +2,22 @@ package seed_test
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
 	files "github.com/ipfs/go-ipfs-files"
 	httpapi "github.com/ipfs/go-ipfs-http-client"
 	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -44,3 +54,264 @@ func TestApiAdd(t *testing.T) {
 	}
 	t.Log(resolved)
 }
+
+func stubNode(t *testing.T, id string, size int) (*httptest.Server, string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/id", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"ID":"%s"}`, id)
+	})
+	mux.HandleFunc("/api/v0/repo/stat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"RepoSize":%d}`, size)
+	})
+	srv := httptest.NewServer(mux)
+	host, port, e := net.SplitHostPort(srv.Listener.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	return srv, fmt.Sprintf("/ip4/%s/tcp/%s", host, port)
+}
+
+// TestAPINode ...
+func TestAPINode(t *testing.T) {
+	srvA, addrA := stubNode(t, "QmNodeA", 300)
+	defer srvA.Close()
+	srvB, addrB := stubNode(t, "QmNodeB", 100)
+	srvC, addrC := stubNode(t, "QmNodeC", 200)
+	defer srvC.Close()
+
+	api := seed.NewAPI(addrA, seed.APINodeArg(addrB, addrC), seed.APIPolicyArg(seed.APIPolicyLeastLoaded))
+	api.CheckHealth(context.Background())
+	if n := api.Node(""); n.ID() != "QmNodeB" {
+		t.Error("least loaded", n.ID())
+	}
+
+	srvB.Close()
+	api.CheckHealth(context.Background())
+	if n := api.Node(""); n.ID() != "QmNodeC" {
+		t.Error("failover", n.ID())
+	}
+
+	api.Policy = seed.APIPolicySticky
+	sticky := api.Node("ABP-123")
+	for i := 0; i < 3; i++ {
+		if n := api.Node("ABP-123"); n != sticky || !n.Healthy() {
+			t.Error("sticky", n.ID(), sticky.ID())
+		}
+	}
+
+	api.Policy = seed.APIPolicyRoundRobin
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		seen[api.Node("").ID()] = true
+	}
+	if len(seen) != 2 || seen["QmNodeB"] {
+		t.Error("round robin", seen)
+	}
+}
+
+// TestPinAdd ...
+func TestPinAdd(t *testing.T) {
+	srv, addr := stubNode(t, "QmNode", 0)
+	defer srv.Close()
+	srv.Config.Handler.(*http.ServeMux).HandleFunc("/api/v0/pin/add", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("progress") != "true" {
+			t.Error("progress not set")
+		}
+		_, _ = fmt.Fprintln(w, `{"Progress":10}`)
+		_, _ = fmt.Fprintln(w, `{"Progress":20}`)
+		_, _ = fmt.Fprintln(w, `{"Pins":["QmPin"]}`)
+	})
+
+	var events []seed.ProgressEvent
+	sink := seed.ProgressFunc(func(event *seed.ProgressEvent) {
+		events = append(events, *event)
+	})
+	api := seed.NewAPI(addr, seed.APIProgressArg(sink, time.Nanosecond))
+	api.BeforeRun(seed.NewSeed())
+	e := seed.PinAdd(api, api.Nodes()[0].API(), "QmPin")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(events) != 3 {
+		t.Fatal(events)
+	}
+	last := events[len(events)-1]
+	if !last.Done || last.Blocks != 20 || last.Kind != seed.ProgressKindPin || last.Target != "QmPin" {
+		t.Error(last)
+	}
+}
+
+// TestLsObject ...
+func TestLsObject(t *testing.T) {
+	segments := map[string]string{
+		"master.m3u8": "QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB",
+		"480P":        "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/ls", func(w http.ResponseWriter, r *http.Request) {
+		for name, hash := range segments {
+			_, _ = fmt.Fprintf(w, `{"Objects":[{"Hash":"%s","Links":[{"Name":"%s","Hash":"%s","Size":100,"Type":2}]}]}`+"\n",
+				r.URL.Query().Get("arg"), name, hash)
+		}
+	})
+	//the size of the sub dir 480P is not in the links
+	mux.HandleFunc("/api/v0/object/stat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"Hash":"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB","CumulativeSize":123456}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+	obj, e := seed.LsObject(context.Background(), api, path.New("/ipfs/QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(obj.Links) != 2 || obj.Link.Size != 123456 || obj.Link.Hash != "QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB" {
+		t.Error(obj.Links, obj.Link)
+	}
+	for _, link := range obj.Links {
+		if segments[link.Name] != link.Hash {
+			t.Error(link)
+		}
+	}
+}
+
+// TestAddFailover ...
+func TestAddFailover(t *testing.T) {
+	srvA, addrA := stubNode(t, "QmNodeA", 0)
+	defer srvA.Close()
+	srvA.Config.Handler.(*http.ServeMux).HandleFunc("/api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprint(w, `{"Message":"repo full","Code":0,"Type":"error"}`)
+	})
+	srvB, addrB := stubNode(t, "QmNodeB", 0)
+	defer srvB.Close()
+	srvB.Config.Handler.(*http.ServeMux).HandleFunc("/api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `{"Name":"add.txt","Hash":"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB","Size":"5"}`)
+	})
+
+	file, e := ioutil.TempFile("", "add")
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, _ = file.WriteString("hello")
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	api := seed.NewAPI(addrA, seed.APINodeArg(addrB), seed.APIPolicyArg(seed.APIPolicySticky))
+	api.BeforeRun(seed.NewSeed())
+	//the local errors do not fail over
+	_, e = seed.AddFile(api, file.Name()+".missing")
+	if e == nil {
+		t.Error("missing file added")
+	}
+	if !api.Nodes()[0].Healthy() || !api.Nodes()[1].Healthy() {
+		t.Error("node unhealthy on the local error")
+	}
+
+	u := &model.Unfinished{Type: model.TypeVideo}
+	resolved, e := seed.AddFile(api, file.Name(), seed.AddUnfinishedArg(u))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if resolved.Cid().String() != "QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB" || u.Node != addrB {
+		t.Error(resolved, u.Node)
+	}
+	if api.Nodes()[0].Healthy() {
+		t.Error("failed node still healthy")
+	}
+}
+
+// TestAddNode ...
+func TestAddNode(t *testing.T) {
+	ids := make(map[string]string)
+	var servers []string
+	for _, id := range []string{"QmNodeA", "QmNodeB"} {
+		srv, addr := stubNode(t, id, 0)
+		defer srv.Close()
+		srv.Config.Handler.(*http.ServeMux).HandleFunc("/api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintln(w, `{"Name":"add.txt","Hash":"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB","Size":"5"}`)
+		})
+		ids[addr] = id
+		servers = append(servers, addr)
+	}
+	file, e := ioutil.TempFile("", "add")
+	if e != nil {
+		t.Fatal(e)
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	api := seed.NewAPI(servers[0], seed.APINodeArg(servers[1]))
+	api.BeforeRun(seed.NewSeed())
+	nodes := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		u := &model.Unfinished{Type: model.TypeVideo}
+		_, e = seed.AddFile(api, file.Name(), seed.AddUnfinishedArg(u))
+		if e != nil {
+			t.Fatal(e)
+		}
+		nodes[u.Node] = true
+		//the steps after the add stay on the node of the add
+		for j := 0; j < 2; j++ {
+			pid, e := seed.MyID(api)
+			if e != nil || pid.ID != ids[u.Node] {
+				t.Error(pid, e, u.Node)
+			}
+		}
+	}
+	if len(nodes) != 2 {
+		t.Error("adds are not spread by the policy", nodes)
+	}
+}
+
+// TestAddOption ...
+func TestAddOption(t *testing.T) {
+	srv, addr := stubNode(t, "QmNode", 0)
+	defer srv.Close()
+	var query url.Values
+	srv.Config.Handler.(*http.ServeMux).HandleFunc("/api/v0/add", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = fmt.Fprintln(w, `{"Name":"add.txt","Hash":"QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB","Size":"5"}`)
+	})
+	file, e := ioutil.TempFile("", "add")
+	if e != nil {
+		t.Fatal(e)
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	raw, cooked := true, false
+	for _, c := range []struct {
+		opt     *model.AddOption
+		version string
+		hash    string
+		raw     string //empty if not sent
+	}{
+		{opt: &model.AddOption{CidVersion: 1}, version: "1", hash: "sha2-256"},
+		{opt: &model.AddOption{CidVersion: 1, RawLeaves: &cooked}, version: "1", hash: "sha2-256", raw: "false"},
+		{opt: &model.AddOption{CidVersion: 0, RawLeaves: &raw}, version: "0", hash: "sha2-256", raw: "true"},
+		{opt: &model.AddOption{CidVersion: 1, Hash: "blake2b-256"}, version: "1", hash: "blake2b-256"},
+		{opt: &model.AddOption{CidVersion: 1, NoCopy: true}, version: "1", hash: "sha2-256", raw: "true"},
+	} {
+		api := seed.NewAPI(addr, seed.APIAddOptionArg(model.TypePoster, c.opt))
+		api.BeforeRun(seed.NewSeed())
+		_, e = seed.AddFile(api, file.Name(), seed.AddTypeArg(model.TypePoster))
+		if e != nil {
+			t.Fatal(e)
+		}
+		if query.Get("cid-version") != c.version || query.Get("hash") != c.hash || query.Get("raw-leaves") != c.raw {
+			t.Errorf("%+v %v", c.opt, query)
+		}
+	}
+
+	api := seed.NewAPI(addr, seed.APIAddOptionArg(model.TypePoster, &model.AddOption{Hash: "unknown"}))
+	api.BeforeRun(seed.NewSeed())
+	query = nil
+	_, e = seed.AddFile(api, file.Name(), seed.AddTypeArg(model.TypePoster))
+	if e == nil || query != nil || !api.Nodes()[0].Healthy() {
+		t.Error("unknown hash", e, query)
+	}
+}
@@ -0,0 +1,230 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// CaptionGroup the group id of the subtitle renditions in the master playlist
+const CaptionGroup = "subs"
+
+// captionDir the dir of the subtitle renditions in the slice output
+const captionDir = "subs"
+
+// UndefinedLanguage ...
+const UndefinedLanguage = "und"
+
+var captionExts = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+// text subtitle codecs could be converted to webvtt
+var captionCodecs = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// Caption a sidecar subtitle file or an embedded subtitle stream
+type Caption struct {
+	File     string //the sidecar file or the video of the embedded stream
+	Stream   int64  //the embedded stream index,-1 is the sidecar
+	Language string
+	VTT      string //the converted webvtt file
+}
+
+// Name the name of the caption in the slice output
+func (c *Caption) Name(i int) string {
+	return fmt.Sprintf("%s-%d", c.Language, i)
+}
+
+// FindCaptions find the sidecar subtitles of the video: <name>[.<language>].{srt,ass,ssa,vtt}
+func FindCaptions(video string) []*Caption {
+	dir, file := filepath.Split(video)
+	name := OnlyName(file)
+	infos, e := ioutil.ReadDir(filepath.Clean(dir))
+	if e != nil {
+		return nil
+	}
+	var captions []*Caption
+	for _, info := range infos {
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		if info.IsDir() || !containsString(captionExts, ext) {
+			continue
+		}
+		base := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+		if base != name && !strings.HasPrefix(base, name+".") {
+			continue
+		}
+		language := strings.TrimPrefix(strings.TrimPrefix(base, name), ".")
+		if language == "" {
+			language = UndefinedLanguage
+		}
+		captions = append(captions, &Caption{
+			File:     filepath.Join(dir, info.Name()),
+			Stream:   -1,
+			Language: strings.ToLower(language),
+		})
+	}
+	return captions
+}
+
+// EmbeddedCaptions the text subtitle streams of the video
+func EmbeddedCaptions(video string, format *cmd.StreamFormat) []*Caption {
+	var captions []*Caption
+	for _, s := range format.Streams {
+		if s.CodecType != "subtitle" || !containsString(captionCodecs, s.CodecName) {
+			continue
+		}
+		language := s.Tags.Language
+		if language == "" {
+			language = UndefinedLanguage
+		}
+		captions = append(captions, &Caption{
+			File:     video,
+			Stream:   s.Index,
+			Language: strings.ToLower(language),
+		})
+	}
+	return captions
+}
+
+// CaptionArgs the ffmpeg args to convert the caption to webvtt
+func CaptionArgs(c *Caption, output string) []string {
+	args := []string{"-y", "-i", c.File}
+	if c.Stream >= 0 {
+		args = append(args, "-map", "0:"+strconv.FormatInt(c.Stream, 10))
+	}
+	return append(args, "-c:s", "webvtt", "-f", "webvtt", output)
+}
+
+// ConvertCaption convert the caption to the webvtt file under dir
+func ConvertCaption(ctx context.Context, c *Caption, dir string, name string) error {
+	output := filepath.Join(dir, name+".vtt")
+	if strings.ToLower(filepath.Ext(c.File)) == ".vtt" && c.Stream < 0 {
+		data, e := ioutil.ReadFile(c.File)
+		if e != nil {
+			return e
+		}
+		e = ioutil.WriteFile(output, data, 0644)
+		if e != nil {
+			return e
+		}
+		c.VTT = output
+		return nil
+	}
+	e := FFMpegRun(ctx, CaptionArgs(c, output)...)
+	if e != nil {
+		return e
+	}
+	c.VTT = output
+	return nil
+}
+
+// captionPlaylist the playlist with the whole webvtt as one segment
+func captionPlaylist(vtt string, duration float64) string {
+	target := int64(duration) + 1
+	return strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:3",
+		"#EXT-X-TARGETDURATION:" + strconv.FormatInt(target, 10),
+		"#EXT-X-MEDIA-SEQUENCE:0",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		fmt.Sprintf("#EXTINF:%.3f,", duration),
+		vtt,
+		"#EXT-X-ENDLIST",
+		"",
+	}, "\n")
+}
+
+// AddCaptionRenditions copy the converted captions into the slice output and reference them from the master playlist,
+// a master playlist of m3u8 is created if m3u8 is a media playlist,returns the master playlist name
+func AddCaptionRenditions(output string, m3u8 string, captions []*Caption, format *cmd.StreamFormat) (string, error) {
+	duration, _ := strconv.ParseFloat(format.Format.Duration, 64)
+	e := os.MkdirAll(filepath.Join(output, captionDir), os.ModePerm)
+	if e != nil {
+		return "", e
+	}
+	var media []string
+	for i, c := range captions {
+		if c.VTT == "" {
+			continue
+		}
+		name := c.Name(i)
+		data, e := ioutil.ReadFile(c.VTT)
+		if e != nil {
+			return "", e
+		}
+		e = ioutil.WriteFile(filepath.Join(output, captionDir, name+".vtt"), data, 0644)
+		if e != nil {
+			return "", e
+		}
+		e = ioutil.WriteFile(filepath.Join(output, captionDir, name+".m3u8"), []byte(captionPlaylist(name+".vtt", duration)), 0644)
+		if e != nil {
+			return "", e
+		}
+		media = append(media, fmt.Sprintf(`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="%s",NAME="%s",LANGUAGE="%s",DEFAULT=%s,AUTOSELECT=YES,URI="%s/%s.m3u8"`,
+			CaptionGroup, name, c.Language, yesNo(len(media) == 0), captionDir, name))
+	}
+	if len(media) == 0 {
+		return m3u8, nil
+	}
+
+	data, e := ioutil.ReadFile(filepath.Join(output, m3u8))
+	if e != nil {
+		return "", e
+	}
+	master := m3u8
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !strings.Contains(string(data), "#EXT-X-STREAM-INF") {
+		master = MasterM3U8
+		bandwidth := format.Format.BitRate
+		if bandwidth == "" {
+			bandwidth = "0"
+		}
+		lines = []string{"#EXTM3U", "#EXT-X-STREAM-INF:BANDWIDTH=" + bandwidth, m3u8}
+	}
+	var out []string
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			line += fmt.Sprintf(`,SUBTITLES="%s"`, CaptionGroup)
+		}
+		out = append(out, line)
+		if i == 0 {
+			out = append(out, media...)
+		}
+	}
+	e = ioutil.WriteFile(filepath.Join(output, master), []byte(strings.Join(out, "\n")+"\n"), 0644)
+	if e != nil {
+		return "", e
+	}
+	return master, nil
+}
+
+// sliceCaptions add the captions to the slice output,the languages are recorded as the caption of the unfinished
+func sliceCaptions(sa *cmd.SplitArgs, u *model.Unfinished, captions []*Caption) error {
+	m3u8 := MustString(sa.M3U8, u.M3U8)
+	master, e := AddCaptionRenditions(sa.Output, m3u8, captions, sa.StreamFormat)
+	if e != nil {
+		return e
+	}
+	sa.M3U8 = master
+	u.M3U8 = master
+	var languages []string
+	for _, c := range captions {
+		if c.VTT != "" && !containsString(languages, c.Language) {
+			languages = append(languages, c.Language)
+		}
+	}
+	u.Caption = strings.Join(languages, ",")
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
@@ -0,0 +1,65 @@
+package seed_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glvd/seed"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// TestCaption ...
+func TestCaption(t *testing.T) {
+	dir, e := ioutil.TempDir("", "caption")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"abp-123.mp4", "abp-123.chi.vtt", "abp-123.srt", "abp-1234.srt", "other.vtt"} {
+		e := ioutil.WriteFile(filepath.Join(dir, name), []byte("WEBVTT\n"), 0644)
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	captions := seed.FindCaptions(filepath.Join(dir, "abp-123.mp4"))
+	if len(captions) != 2 || captions[0].Language != "chi" || captions[1].Language != seed.UndefinedLanguage {
+		t.Fatalf("%+v", captions)
+	}
+	e = seed.ConvertCaption(context.Background(), captions[0], dir, "converted")
+	if e != nil || captions[0].VTT != filepath.Join(dir, "converted.vtt") {
+		t.Fatal(e, captions[0].VTT)
+	}
+
+	e = ioutil.WriteFile(filepath.Join(dir, "media.m3u8"), []byte("#EXTM3U\n#EXT-X-TARGETDURATION:10\n"), 0644)
+	if e != nil {
+		t.Fatal(e)
+	}
+	format := &cmd.StreamFormat{Format: cmd.Format{Duration: "60.5", BitRate: "1000"}}
+	master, e := seed.AddCaptionRenditions(dir, "media.m3u8", captions, format)
+	if e != nil {
+		t.Fatal(e)
+	}
+	data, e := ioutil.ReadFile(filepath.Join(dir, master))
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, v := range []string{
+		`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="chi-0",LANGUAGE="chi",DEFAULT=YES,AUTOSELECT=YES,URI="subs/chi-0.m3u8"`,
+		`#EXT-X-STREAM-INF:BANDWIDTH=1000,SUBTITLES="subs"`,
+		"\nmedia.m3u8\n",
+	} {
+		if !strings.Contains(string(data), v) {
+			t.Errorf("%s not in %s", v, data)
+		}
+	}
+	if strings.Contains(string(data), "und-1") {
+		t.Error("unconverted caption is added")
+	}
+	if _, e := os.Stat(filepath.Join(dir, "subs", "chi-0.vtt")); e != nil {
+		t.Error(e)
+	}
+}
@@ -1,14 +1,13 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/glvd/seed"
-	cmd "github.com/godcong/go-ffmpeg-cmd"
 )
 
 func main() {
@@ -23,17 +22,19 @@ func main() {
 		return
 	}
 	files := seed.GetFiles(dir)
-	ctx := cmd.FFmpegContext()
+	profile := seed.DefaultTranscodeProfile
 	for _, f := range files {
 		ext := filepath.Ext(f)
-		if strings.ToUpper(ext) == ".ISO" || strings.ToUpper(ext) == ".WMV" {
+		if !seed.IsVideo(f) && strings.ToUpper(ext) != ".ISO" {
+			continue
+		}
+		b, err := profile.TranscodeTo(context.Background(), f, f+profile.Ext)
+		if err != nil {
+			log.Println(f, err)
+			continue
+		}
+		if b {
 			log.Println("transfer:", f)
-			input := fmt.Sprintf("-y -i %s %s", f, f+".mp4")
-			err := cmd.FFMpegRun(ctx, input)
-			if err != nil {
-				log.Println(err)
-				return
-			}
 		}
 	}
 
@@ -0,0 +1,187 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// ImageProfile the size and the format of the extracted image
+type ImageProfile struct {
+	Width  int    //0 keeps the aspect of the height
+	Height int    //0 keeps the aspect of the width
+	Format string //jpg,png,webp
+}
+
+// FrameExtract extract the poster and the thumb from the representative frame of the video
+type FrameExtract struct {
+	Poster       *ImageProfile //nil to skip
+	Thumb        *ImageProfile //nil to skip
+	Positions    []float64     //the candidate positions in the duration
+	MinLuma      float64       //the darker frames are skipped
+	MinDeviation float64       //the near uniform frames are skipped
+}
+
+// DefaultFrameExtract 720P poster and 320 width thumb
+var DefaultFrameExtract = &FrameExtract{
+	Poster:       &ImageProfile{Height: 720, Format: "jpg"},
+	Thumb:        &ImageProfile{Width: 320, Format: "jpg"},
+	Positions:    []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7},
+	MinLuma:      40,
+	MinDeviation: 20,
+}
+
+// ProcessFrameArg extract the poster and the thumb of the sources under dir
+func ProcessFrameArg(extract *FrameExtract, dir string) ProcessArgs {
+	return func(p *Process) {
+		if extract == nil {
+			extract = DefaultFrameExtract
+		}
+		p.Frame = extract
+		p.FrameDir = dir
+	}
+}
+
+// FrameArgs the ffmpeg args to extract the frame at the seconds of file to output
+func FrameArgs(file string, at float64, output string) []string {
+	return []string{"-y", "-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", file, "-frames:v", "1", output}
+}
+
+// Args the ffmpeg args to scale the frame to output
+func (p *ImageProfile) Args(frame string, output string) []string {
+	args := []string{"-y", "-i", frame, "-vf", fmt.Sprintf("scale=%d:%d", scaleSize(p.Width), scaleSize(p.Height))}
+	if p.Format == "jpg" || p.Format == "jpeg" {
+		args = append(args, "-q:v", "2")
+	}
+	return append(args, output)
+}
+
+// Output the image of the name under dir
+func (p *ImageProfile) Output(dir string, name string) string {
+	return filepath.Join(dir, name+"."+p.Format)
+}
+
+// scaleSize the even size keeps the aspect if not set
+func scaleSize(size int) int {
+	if size <= 0 {
+		return -2
+	}
+	return size
+}
+
+// FrameScore the mean luma and the standard deviation of the luma of the image
+func FrameScore(img image.Image) (luma float64, deviation float64) {
+	bounds := img.Bounds()
+	//sample about 160x160 pixels of the large frame
+	step := bounds.Dx() / 160
+	if step < 1 {
+		step = 1
+	}
+	var sum, square, n float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			sum += l
+			square += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	luma = sum / n
+	return luma, math.Sqrt(math.Max(square/n-luma*luma, 0))
+}
+
+// Pass the frame is neither dark nor near uniform
+func (f *FrameExtract) Pass(luma float64, deviation float64) bool {
+	return luma >= f.MinLuma && deviation >= f.MinDeviation
+}
+
+// BestFrame extract the candidate frames of file under dir,returns the frame with the most detail of the passed frames,
+// the most detail frame is returned if none is passed
+func (f *FrameExtract) BestFrame(ctx context.Context, file string, format *cmd.StreamFormat, dir string) (string, error) {
+	duration, _ := strconv.ParseFloat(format.Format.Duration, 64)
+	positions := f.Positions
+	if len(positions) == 0 || duration <= 0 {
+		positions = []float64{0}
+	}
+	best, bestDeviation, passed := "", -1.0, false
+	for i, pos := range positions {
+		frame := filepath.Join(dir, fmt.Sprintf("frame-%d.png", i))
+		e := FFMpegRun(ctx, FrameArgs(file, pos*duration, frame)...)
+		if e != nil {
+			log.With("file", file, "position", pos).Error(e)
+			continue
+		}
+		luma, deviation, e := frameScore(frame)
+		if e != nil {
+			log.With("frame", frame).Error(e)
+			continue
+		}
+		pass := f.Pass(luma, deviation)
+		if (pass && !passed) || (pass == passed && deviation > bestDeviation) {
+			best, bestDeviation, passed = frame, deviation, pass
+		}
+	}
+	if best == "" {
+		return "", errors.New("no frame extracted")
+	}
+	if !passed {
+		log.With("file", file, "frame", best).Warn("no frame passed")
+	}
+	return best, nil
+}
+
+func frameScore(frame string) (float64, float64, error) {
+	file, e := os.Open(frame)
+	if e != nil {
+		return 0, 0, e
+	}
+	defer file.Close()
+	img, e := png.Decode(file)
+	if e != nil {
+		return 0, 0, e
+	}
+	luma, deviation := FrameScore(img)
+	return luma, deviation, nil
+}
+
+// Extract extract the poster and the thumb of file named name under dir,the skipped image is returned empty
+func (f *FrameExtract) Extract(ctx context.Context, file string, format *cmd.StreamFormat, dir string, name string) (poster string, thumb string, e error) {
+	if f.Poster == nil && f.Thumb == nil {
+		return "", "", nil
+	}
+	e = os.MkdirAll(dir, os.ModePerm)
+	if e != nil {
+		return "", "", e
+	}
+	frame, e := f.BestFrame(ctx, file, format, dir)
+	if e != nil {
+		return "", "", e
+	}
+	if f.Poster != nil {
+		poster = f.Poster.Output(dir, name+".poster")
+		e = FFMpegRun(ctx, f.Poster.Args(frame, poster)...)
+		if e != nil {
+			return "", "", e
+		}
+	}
+	if f.Thumb != nil {
+		thumb = f.Thumb.Output(dir, name+".thumb")
+		e = FFMpegRun(ctx, f.Thumb.Args(frame, thumb)...)
+		if e != nil {
+			return "", "", e
+		}
+	}
+	return poster, thumb, nil
+}
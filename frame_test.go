@@ -0,0 +1,48 @@
+package seed_test
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/glvd/seed"
+)
+
+func fillImage(fn func(x, y int) uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 64, 36))
+	for y := 0; y < 36; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{Y: fn(x, y)})
+		}
+	}
+	return img
+}
+
+// TestFrameScore ...
+func TestFrameScore(t *testing.T) {
+	extract := seed.DefaultFrameExtract
+	black := fillImage(func(x, y int) uint8 { return 2 })
+	if luma, deviation := seed.FrameScore(black); luma > 3 || extract.Pass(luma, deviation) {
+		t.Error("black frame passed", luma, deviation)
+	}
+	gray := fillImage(func(x, y int) uint8 { return 128 })
+	if luma, deviation := seed.FrameScore(gray); deviation > 1 || extract.Pass(luma, deviation) {
+		t.Error("uniform frame passed", luma, deviation)
+	}
+	gradient := fillImage(func(x, y int) uint8 { return uint8(x * 4) })
+	if luma, deviation := seed.FrameScore(gradient); !extract.Pass(luma, deviation) {
+		t.Error("gradient frame not passed", luma, deviation)
+	}
+
+	args := strings.Join(extract.Thumb.Args("frame.png", "thumb.jpg"), " ")
+	if args != "-y -i frame.png -vf scale=320:-2 -q:v 2 thumb.jpg" {
+		t.Error(args)
+	}
+	if output := extract.Poster.Output("dir", "abc.poster"); output != "dir/abc.poster.jpg" {
+		t.Error(output)
+	}
+	if args := strings.Join(seed.FrameArgs("in.mp4", 12.5, "frame.png"), " "); args != "-y -ss 12.500 -i in.mp4 -frames:v 1 frame.png" {
+		t.Error(args)
+	}
+}
@@ -15,6 +15,7 @@ require (
 	github.com/libp2p/go-libp2p-core v0.0.3
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/multiformats/go-multiaddr v0.0.4
+	github.com/multiformats/go-multihash v0.0.5
 	github.com/olivere/elastic v6.2.23+incompatible // indirect
 	github.com/pelletier/go-toml v1.4.0
 	github.com/xormsharp/xorm v1.0.0
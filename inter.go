@@ -56,6 +56,10 @@ const (
 	StepperUpdate
 	// StepperTask ...
 	StepperTask
+	// StepperAnnounce ...
+	StepperAnnounce
+	// StepperKeyServer ...
+	StepperKeyServer
 
 	// StepperMax ...
 	StepperMax
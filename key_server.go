@@ -0,0 +1,272 @@
+package seed
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glvd/seed/model"
+	"github.com/xormsharp/xorm"
+)
+
+// DefaultKeyServerAddr ...
+const DefaultKeyServerAddr = ":8089"
+
+// DefaultKeyTokenExpire ...
+const DefaultKeyTokenExpire = 6 * time.Hour
+
+// KeyServerPath the path prefix of the key uri
+const KeyServerPath = "/key/"
+
+// KeyLoader load the stored key by the key id
+type KeyLoader interface {
+	LoadKey(ctx context.Context, keyID string) (*model.VideoKey, error)
+}
+
+// KeyLoaderFunc ...
+type KeyLoaderFunc func(ctx context.Context, keyID string) (*model.VideoKey, error)
+
+// LoadKey ...
+func (f KeyLoaderFunc) LoadKey(ctx context.Context, keyID string) (*model.VideoKey, error) {
+	return f(ctx, keyID)
+}
+
+// KeyServer deliver the hls keys to the players holding a valid token
+type KeyServer struct {
+	*Thread
+	Addr        string
+	Secret      []byte //sign the tokens
+	MasterKey   []byte //open the stored keys
+	Expire      time.Duration
+	Loader      KeyLoader
+	AllowOrigin string //the allowed origin of the web players,empty to send no cors headers
+	server      *http.Server
+}
+
+// KeyServerArgs ...
+type KeyServerArgs func(s *KeyServer)
+
+// KeyServerAddrArg ...
+func KeyServerAddrArg(addr string) KeyServerArgs {
+	return func(s *KeyServer) {
+		s.Addr = addr
+	}
+}
+
+// KeyServerExpireArg ...
+func KeyServerExpireArg(expire time.Duration) KeyServerArgs {
+	return func(s *KeyServer) {
+		s.Expire = expire
+	}
+}
+
+// KeyServerLoaderArg load the keys from loader instead of the database thread
+func KeyServerLoaderArg(loader KeyLoader) KeyServerArgs {
+	return func(s *KeyServer) {
+		s.Loader = loader
+	}
+}
+
+// KeyServerCORSArg allow the web players of origin to fetch the keys,* to allow all
+func KeyServerCORSArg(origin string) KeyServerArgs {
+	return func(s *KeyServer) {
+		s.AllowOrigin = origin
+	}
+}
+
+// SliceKeyServerArg write the key uri of the key server base url into the m3u8
+func SliceKeyServerArg(base string) SliceArgs {
+	return SliceKeyURIArg(strings.TrimSuffix(base, "/") + KeyServerPath)
+}
+
+// NewKeyServer ...
+func NewKeyServer(secret []byte, master []byte, args ...KeyServerArgs) *KeyServer {
+	s := &KeyServer{
+		Thread:    NewThread(),
+		Addr:      DefaultKeyServerAddr,
+		Secret:    secret,
+		MasterKey: master,
+		Expire:    DefaultKeyTokenExpire,
+	}
+	for _, argFn := range args {
+		argFn(s)
+	}
+	return s
+}
+
+// Option ...
+func (s *KeyServer) Option(seeder Seeder) {
+	seeder.SetBaseThread(StepperKeyServer, s)
+}
+
+// Push ...
+func (s *KeyServer) Push(v interface{}) error {
+	return errors.New("key server accept no push")
+}
+
+// Run ...
+func (s *KeyServer) Run(ctx context.Context) {
+	log.With("addr", s.Addr).Info("key server running")
+	e := s.serve(ctx)
+	if e != nil {
+		log.With("addr", s.Addr).Error(e)
+		//the seeder is not kept waiting by the failed server
+		s.SetState(StateWaiting)
+		<-ctx.Done()
+	}
+	s.Finished()
+}
+
+// serve listen on the addr and serve the keys until the context is done,
+// the server is waiting for the requests once the listener is up
+func (s *KeyServer) serve(ctx context.Context) error {
+	if len(s.Secret) == 0 {
+		return errors.New("key server secret is empty")
+	}
+	if s.Loader == nil {
+		s.Loader = KeyLoaderFunc(s.loadKey)
+	}
+	l, e := net.Listen("tcp", s.Addr)
+	if e != nil {
+		return e
+	}
+	s.server = &http.Server{
+		Addr:    s.Addr,
+		Handler: s,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.server.Serve(l)
+	}()
+	s.SetState(StateWaiting)
+	select {
+	case <-ctx.Done():
+		c, cancel := context.WithTimeout(context.Background(), TimeOutLimit)
+		defer cancel()
+		if e := s.server.Shutdown(c); e != nil {
+			log.Error(e)
+		}
+		return nil
+	case e := <-done:
+		if e == http.ErrServerClosed {
+			return nil
+		}
+		return e
+	}
+}
+
+// loadKey load the key through the database thread
+func (s *KeyServer) loadKey(ctx context.Context, keyID string) (*model.VideoKey, error) {
+	type result struct {
+		key *model.VideoKey
+		e   error
+	}
+	r := make(chan result, 1)
+	e := s.PushTo(DatabaseCallback(keyID, func(database *Database, eng *xorm.Engine, v interface{}) (e error) {
+		key, e := model.FindVideoKey(eng.Where(""), v.(string))
+		r <- result{key: key, e: e}
+		return nil
+	}))
+	if e != nil {
+		return nil, e
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case v := <-r:
+		return v.key, v.e
+	}
+}
+
+// Token sign a token of the key id valid until the expire time: <expire>.<hmac>
+func (s *KeyServer) Token(keyID string, expire time.Time) string {
+	exp := strconv.FormatInt(expire.Unix(), 10)
+	return exp + "." + s.sign(keyID, exp)
+}
+
+// NewToken sign a token of the key id valid for the expire duration
+func (s *KeyServer) NewToken(keyID string) string {
+	return s.Token(keyID, time.Now().Add(s.Expire))
+}
+
+// Verify check the token of the key id
+func (s *KeyServer) Verify(keyID string, token string) error {
+	if len(s.Secret) == 0 {
+		return errors.New("key server secret is empty")
+	}
+	v := strings.SplitN(token, ".", 2)
+	if len(v) != 2 {
+		return errors.New("wrong token format")
+	}
+	exp, e := strconv.ParseInt(v[0], 10, 64)
+	if e != nil {
+		return e
+	}
+	if !hmac.Equal([]byte(v[1]), []byte(s.sign(keyID, v[0]))) {
+		return errors.New("wrong token")
+	}
+	if time.Now().Unix() > exp {
+		return errors.New("token expired")
+	}
+	return nil
+}
+
+func (s *KeyServer) sign(keyID string, exp string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	_, _ = mac.Write([]byte(keyID + "|" + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serve /key/<key id>,the token is set by the token query or the bearer authorization
+func (s *KeyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", s.AllowOrigin)
+		if s.AllowOrigin != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+	}
+	if r.Method == http.MethodOptions && s.AllowOrigin != "" && strings.HasPrefix(r.URL.Path, KeyServerPath) {
+		//the preflight of the bearer authorization
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, KeyServerPath) {
+		http.NotFound(w, r)
+		return
+	}
+	keyID := strings.TrimPrefix(r.URL.Path, KeyServerPath)
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if e := s.Verify(keyID, token); e != nil {
+		log.With("key", keyID, "remote", r.RemoteAddr).Warn(e.Error())
+		http.Error(w, e.Error(), http.StatusForbidden)
+		return
+	}
+	key, e := s.Loader.LoadKey(r.Context(), keyID)
+	if e != nil {
+		log.With("key", keyID).Error(e)
+		http.NotFound(w, r)
+		return
+	}
+	plain, e := model.OpenKey(s.MasterKey, key.Key)
+	if e != nil {
+		log.With("key", keyID).Error(e)
+		http.Error(w, "key unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(plain)
+}
@@ -0,0 +1,127 @@
+package seed_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+)
+
+// TestKeyServer ...
+func TestKeyServer(t *testing.T) {
+	master := []byte("master")
+	key := []byte("0123456789abcdef")
+	sealed, e := model.SealKey(master, key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ks := seed.NewKeyServer([]byte("secret"), master, seed.KeyServerLoaderArg(seed.KeyLoaderFunc(func(ctx context.Context, keyID string) (*model.VideoKey, error) {
+		if keyID != "k1" {
+			return nil, errors.New("video key not found")
+		}
+		return &model.VideoKey{KeyID: keyID, Key: sealed}, nil
+	})))
+	srv := httptest.NewServer(ks)
+	defer srv.Close()
+
+	get := func(path string, header string) (int, []byte) {
+		req, e := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if header != "" {
+			req.Header.Set("Authorization", "Bearer "+header)
+		}
+		resp, e := http.DefaultClient.Do(req)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+
+	if code, data := get("/key/k1?token="+ks.NewToken("k1"), ""); code != http.StatusOK || !bytes.Equal(data, key) {
+		t.Errorf("%d %q", code, data)
+	}
+	if code, data := get("/key/k1", ks.NewToken("k1")); code != http.StatusOK || !bytes.Equal(data, key) {
+		t.Errorf("%d %q", code, data)
+	}
+	for _, v := range []string{
+		"/key/k1",
+		"/key/k1?token=" + ks.NewToken("k2"),
+		"/key/k1?token=" + ks.Token("k1", time.Now().Add(-time.Minute)),
+	} {
+		if code, _ := get(v, ""); code != http.StatusForbidden {
+			t.Errorf("%s: %d", v, code)
+		}
+	}
+	if code, _ := get("/key/k2?token="+ks.NewToken("k2"), ""); code != http.StatusNotFound {
+		t.Errorf("not found: %d", code)
+	}
+
+	sli := seed.NewSlice(seed.SliceKeyServerArg("http://localhost:8089/"))
+	if sli.KeyURI != "http://localhost:8089/key/" {
+		t.Error(sli.KeyURI)
+	}
+}
+
+// TestKeyServerRun ...
+func TestKeyServerRun(t *testing.T) {
+	for _, secret := range []string{"secret", ""} {
+		ks := seed.NewKeyServer([]byte(secret), []byte("master"), seed.KeyServerAddrArg("127.0.0.1:0"))
+		ctx, cancel := context.WithCancel(context.Background())
+		go ks.Run(ctx)
+		//the seeder waits for the server in the waiting state,the server without secret is not started
+		deadline := time.Now().Add(5 * time.Second)
+		for ks.State() != seed.StateWaiting && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if ks.State() != seed.StateWaiting {
+			t.Errorf("%q: state %v", secret, ks.State())
+		}
+		cancel()
+		select {
+		case <-ks.Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("%q: not finished", secret)
+		}
+	}
+	ks := seed.NewKeyServer(nil, []byte("master"))
+	if e := ks.Verify("k1", ks.NewToken("k1")); e == nil {
+		t.Error("verified without secret")
+	}
+}
+
+// TestKeyServerCORS ...
+func TestKeyServerCORS(t *testing.T) {
+	ks := seed.NewKeyServer([]byte("secret"), []byte("master"), seed.KeyServerCORSArg("https://player.example"))
+	req := httptest.NewRequest(http.MethodOptions, "/key/k1", nil)
+	req.Header.Set("Origin", "https://player.example")
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	w := httptest.NewRecorder()
+	ks.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://player.example" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Authorization" {
+		t.Errorf("%d %v", w.Code, w.Header())
+	}
+	//the errors are readable by the player
+	w = httptest.NewRecorder()
+	ks.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/key/k1", nil))
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "https://player.example" {
+		t.Errorf("%d %v", w.Code, w.Header())
+	}
+	//no cors headers by default
+	w = httptest.NewRecorder()
+	seed.NewKeyServer([]byte("secret"), nil).ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/key/k1", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("%d %v", w.Code, w.Header())
+	}
+}
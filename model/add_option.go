@@ -0,0 +1,23 @@
+package model
+
+// AddOption unixfs add option
+type AddOption struct {
+	CidVersion int    `json:"cid_version"` //CID版本
+	RawLeaves  *bool  `json:"raw_leaves"`  //raw叶节点,不设置时cid v1默认使用
+	Chunker    string `json:"chunker"`     //分块方式：size-262144,rabin-262144-524288-1048576
+	Hash       string `json:"hash"`        //哈希函数：sha2-256,blake2b-256
+	Trickle    bool   `json:"trickle"`     //trickle布局
+	OnlyHash   bool   `json:"only_hash"`   //只计算哈希
+	NoCopy     bool   `json:"no_copy"`     //filestore引用源文件,不复制
+}
+
+// Clone ...
+func (opt *AddOption) Clone() (n *AddOption) {
+	n = new(AddOption)
+	*n = *opt
+	if opt.RawLeaves != nil {
+		raw := *opt.RawLeaves
+		n.RawLeaves = &raw
+	}
+	return
+}
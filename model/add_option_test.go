@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestAddOption ...
+func TestAddOption(t *testing.T) {
+	opt := new(AddOption)
+	e := json.Unmarshal([]byte(`{"cid_version":1,"hash":"blake2b-256"}`), opt)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if opt.RawLeaves != nil || opt.CidVersion != 1 {
+		t.Errorf("%+v", opt)
+	}
+	e = json.Unmarshal([]byte(`{"raw_leaves":false}`), opt)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if opt.RawLeaves == nil || *opt.RawLeaves {
+		t.Errorf("%+v", opt)
+	}
+
+	n := opt.Clone()
+	*n.RawLeaves = true
+	if *opt.RawLeaves || n.Hash != "blake2b-256" {
+		t.Errorf("%+v %+v", opt, n)
+	}
+}
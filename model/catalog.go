@@ -0,0 +1,62 @@
+package model
+
+import (
+	"github.com/xormsharp/xorm"
+)
+
+// Catalog 订阅或发布的目录(ipns)
+type Catalog struct {
+	Model      `xorm:"extends"`
+	Name       string `xorm:"name" json:"name"`               //ipns名
+	Record     string `xorm:"record" json:"record"`           //已应用(发布)的记录
+	Catalog    string `xorm:"catalog" json:"catalog"`         //已应用(发布)的目录
+	Count      int    `json:"count"`                          //视频数
+	PublishKey string `xorm:"publish_key" json:"publish_key"` //发布用的key,订阅时为空
+}
+
+func init() {
+	RegisterTable(Catalog{})
+}
+
+// FindCatalog find the subscribed catalog of the ipns name
+func FindCatalog(session *xorm.Session, name string) (catalog *Catalog, e error) {
+	catalog = new(Catalog)
+	b, e := MustSession(session).Where("name = ? AND publish_key = ?", name, "").Get(catalog)
+	if e != nil {
+		return nil, e
+	}
+	if !b {
+		return nil, nil
+	}
+	return catalog, nil
+}
+
+// FindPublishedCatalog find the catalog published under the key
+func FindPublishedCatalog(session *xorm.Session, key string) (catalog *Catalog, e error) {
+	catalog = new(Catalog)
+	b, e := MustSession(session).Where("publish_key = ?", key).Get(catalog)
+	if e != nil {
+		return nil, e
+	}
+	if !b {
+		return nil, nil
+	}
+	return catalog, nil
+}
+
+// AddOrUpdateCatalog ...
+func AddOrUpdateCatalog(session *xorm.Session, catalog *Catalog) (e error) {
+	tmp := new(Catalog)
+	found, e := session.Clone().Where("name = ? AND publish_key = ?", catalog.Name, catalog.PublishKey).Get(tmp)
+	if e != nil {
+		return e
+	}
+	if found {
+		catalog.ID = tmp.ID
+		catalog.Version = tmp.Version
+		_, e = session.Clone().ID(catalog.ID).Update(catalog)
+		return e
+	}
+	_, e = session.Clone().InsertOne(catalog)
+	return
+}
@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+// TestPublishedCatalog ...
+func TestPublishedCatalog(t *testing.T) {
+	eng, closer := testEngine(t, Catalog{})
+	defer closer()
+	e := AddOrUpdateCatalog(eng.Where(""), &Catalog{Name: "/ipns/QmName", Record: "QmSubscribed"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	published, e := FindPublishedCatalog(eng.Where(""), "catalog")
+	if e != nil || published != nil {
+		t.Fatal(published, e)
+	}
+	for _, record := range []string{"QmFirst", "QmSecond"} {
+		e = AddOrUpdateCatalog(eng.Where(""), &Catalog{Name: "/ipns/QmName", Record: record, PublishKey: "catalog"})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	published, e = FindPublishedCatalog(eng.Where(""), "catalog")
+	if e != nil || published == nil || published.Record != "QmSecond" {
+		t.Fatal(published, e)
+	}
+	subscribed, e := FindCatalog(eng.Where(""), "/ipns/QmName")
+	if e != nil || subscribed == nil || subscribed.Record != "QmSubscribed" {
+		t.Fatal(subscribed, e)
+	}
+}
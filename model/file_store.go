@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xormsharp/xorm"
+)
+
+// FileStore 源文件引用(filestore nocopy)
+type FileStore struct {
+	Model   `xorm:"extends"`
+	Hash    string    `xorm:"hash" json:"hash"`               //哈希地址
+	Path    string    `xorm:"varchar(1024) path" json:"path"` //源文件路径
+	Size    int64     `json:"size"`                           //文件大小
+	ModTime time.Time `json:"mod_time"`                       //修改时间,数据库只保存到秒
+	Node    string    `json:"node"`                           //添加节点
+}
+
+func init() {
+	RegisterTable(FileStore{})
+}
+
+// AllFileStore ...
+func AllFileStore(session *xorm.Session, limit int, start ...int) (stores *[]*FileStore, e error) {
+	stores = new([]*FileStore)
+	session = MustSession(session)
+	if limit > 0 {
+		session = session.Limit(limit, start...)
+	}
+	if e = session.Find(stores); e != nil {
+		return nil, e
+	}
+	return stores, nil
+}
+
+// AddOrUpdateFileStore ...
+func AddOrUpdateFileStore(session *xorm.Session, store *FileStore) (e error) {
+	tmp := new(FileStore)
+	var found bool
+	if store.ID != "" {
+		found, e = session.Clone().ID(store.ID).Get(tmp)
+	} else {
+		found, e = session.Clone().Where("hash = ?", store.Hash).
+			And("node = ?", store.Node).Get(tmp)
+	}
+	if e != nil {
+		return e
+	}
+	if found {
+		store.ID = tmp.ID
+		store.Version = tmp.Version
+		_, e = session.Clone().ID(store.ID).Update(store)
+		return e
+	}
+	_, e = session.Clone().InsertOne(store)
+	return
+}
+
+// DeleteFileStore ...
+func DeleteFileStore(session *xorm.Session, store *FileStore) (e error) {
+	_, e = MustSession(session).ID(store.ID).Delete(new(FileStore))
+	return e
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/xormsharp/xorm"
+)
+
+func testEngine(t *testing.T, tables ...interface{}) (*xorm.Engine, func()) {
+	dir, e := ioutil.TempDir("", "model")
+	if e != nil {
+		t.Fatal(e)
+	}
+	eng, e := InitSQLite3(filepath.Join(dir, "test.db"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = eng.Sync2(tables...)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return eng, func() {
+		_ = eng.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// TestAddOrUpdateFileStore ...
+func TestAddOrUpdateFileStore(t *testing.T) {
+	eng, closer := testEngine(t, FileStore{})
+	defer closer()
+	now := time.Now()
+	e := AddOrUpdateFileStore(eng.Where(""), &FileStore{Hash: "QmA", Path: "/a/1.mp4", Size: 1, ModTime: now, Node: "node"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	//the same hash on the same node is updated
+	e = AddOrUpdateFileStore(eng.Where(""), &FileStore{Hash: "QmA", Path: "/b/1.mp4", Size: 1, ModTime: now, Node: "node"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = AddOrUpdateFileStore(eng.Where(""), &FileStore{Hash: "QmA", Path: "/a/1.mp4", Size: 1, ModTime: now, Node: "other"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	stores, e := AllFileStore(eng.Where(""), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	nodes := make(map[string]*FileStore)
+	for _, store := range *stores {
+		nodes[store.Node] = store
+	}
+	if len(nodes) != 2 || nodes["node"].Path != "/b/1.mp4" {
+		t.Fatalf("%+v", *stores)
+	}
+	if nodes["node"].ModTime.Unix() != now.Unix() {
+		t.Error(nodes["node"].ModTime, now)
+	}
+
+	e = DeleteFileStore(eng.Where(""), nodes["node"])
+	if e != nil {
+		t.Fatal(e)
+	}
+	stores, e = AllFileStore(eng.Where(""), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(*stores) != 1 || (*stores)[0].Node != "other" {
+		t.Errorf("%+v", *stores)
+	}
+}
@@ -0,0 +1,8 @@
+package model
+
+// Rendition a variant stream of the master playlist
+type Rendition struct {
+	Sharpness string `json:"sharpness"` //清晰度
+	BitRate   int64  `json:"bit_rate"`  //码率(K)
+	M3U8      string `json:"m3u8"`      //相对于切片目录的M3U8名
+}
@@ -27,22 +27,33 @@ const TypeThumb Type = "thumb"
 // TypeCaption caption file
 const TypeCaption Type = "caption"
 
+// TypePreview preview clip
+const TypePreview Type = "preview"
+
 // Unfinished 未分类
 type Unfinished struct {
 	Model       `xorm:"extends"`
-	Checksum    string       `xorm:"default() checksum"`            //sum值
-	Type        Type         `xorm:"default() type"`                //类型
-	Relate      string       `xorm:"default()" json:"relate"`       //关联信息
-	Name        string       `xorm:"default() name"`                //名称
-	Hash        string       `xorm:"default() hash"`                //哈希地址
-	Sharpness   string       `xorm:"default()" json:"sharpness"`    //清晰度
-	Caption     string       `xorm:"default()" json:"caption"`      //字幕
-	Encrypt     bool         `json:"encrypt"`                       //加密
-	Key         string       `xorm:"default()" json:"key"`          //秘钥
-	M3U8        string       `xorm:"m3u8 default()" json:"m3u8"`    //M3U8名
-	SegmentFile string       `xorm:"default()" json:"segment_file"` //ts切片名
-	Sync        bool         `xorm:"notnull default(0)"`            //是否已同步
-	Object      *VideoObject `xorm:"json" json:"object,omitempty"`  //视频信息
+	Checksum    string       `xorm:"default() checksum"`               //sum值
+	Type        Type         `xorm:"default() type"`                   //类型
+	Relate      string       `xorm:"default()" json:"relate"`          //关联信息
+	Name        string       `xorm:"default() name"`                   //名称
+	Hash        string       `xorm:"default() hash"`                   //哈希地址
+	Sharpness   string       `xorm:"default()" json:"sharpness"`       //清晰度
+	Caption     string       `xorm:"default()" json:"caption"`         //字幕
+	Language    []string     `xorm:"json" json:"language"`             //音轨语言
+	Encrypt     bool         `json:"encrypt"`                          //加密
+	Key         string       `xorm:"default()" json:"key"`             //秘钥
+	M3U8        string       `xorm:"m3u8 default()" json:"m3u8"`       //M3U8名
+	SegmentFile string       `xorm:"default()" json:"segment_file"`    //ts切片名
+	SliceFormat string       `xorm:"default()" json:"slice_format"`    //切片格式:hls,fmp4,dash,cmaf
+	MPD         string       `xorm:"mpd default()" json:"mpd"`         //MPD名
+	Trickplay   string       `xorm:"default()" json:"trickplay"`       //进度条预览vtt
+	Sync        bool         `xorm:"notnull default(0)"`               //是否已同步
+	Extract     bool         `xorm:"default(0)" json:"extract"`        //从视频截取
+	Node        string       `xorm:"default()" json:"node"`            //添加节点
+	AddOption   *AddOption   `xorm:"json" json:"add_option,omitempty"` //添加参数
+	Object      *VideoObject `xorm:"json" json:"object,omitempty"`     //视频信息
+	Renditions  []*Rendition `xorm:"json" json:"renditions,omitempty"` //多码率切片
 }
 
 // GetID ...
@@ -2,45 +2,55 @@ package model
 
 import (
 	"strings"
+	"time"
 
 	"github.com/xormsharp/xorm"
 )
 
 // Video ...
 type Video struct {
-	Model        `xorm:"extends" json:"-"`
-	FindNo       string   `json:"-"`                              //查找号
-	Bangumi      string   `xorm:"bangumi" json:"bangumi"`         //番組
-	Intro        string   `xorm:"varchar(2048)" json:"intro"`     //简介
-	Alias        []string `xorm:"json" json:"alias"`              //别名，片名
-	ThumbHash    string   `xorm:"thumb_hash" json:"thumb_hash"`   //缩略图
-	PosterHash   string   `xorm:"poster_hash" json:"poster_hash"` //海报地址
-	SourceHash   string   `xorm:"source_hash" json:"source_hash"` //原片地址
-	M3U8Hash     string   `xorm:"m3u8_hash" json:"m3u8_hash"`     //切片地址
-	Key          string   `json:"-"`                              //秘钥
-	M3U8         string   `xorm:"m3u8" json:"-"`                  //M3U8名
-	Role         []string `xorm:"json" json:"role"`               //主演
-	Director     string   `json:"-"`                              //导演
-	Systematics  string   `json:"-"`                              //分级
-	Season       string   `json:"-"`                              //季
-	TotalEpisode string   `json:"-"`                              //总集数
-	Episode      string   `json:"-"`                              //集数
-	Producer     string   `json:"-"`                              //生产商
-	Publisher    string   `json:"-"`                              //发行商
-	Type         string   `json:"-"`                              //类型：film，FanDrama
-	Format       string   `json:"format"`                         //输出格式：3D，2D,VR(VR格式：Half-SBS：左右半宽,Half-OU：上下半高,SBS：左右全宽)
-	Language     string   `json:"-"`                              //语言
-	Caption      string   `json:"-"`                              //字幕
-	Group        string   `json:"-"`                              //分组
-	Index        string   `json:"-"`                              //索引
-	Date         string   `json:"-"`                              //发行日期
-	Sharpness    string   `json:"sharpness"`                      //清晰度
-	Visit        uint64   `json:"-" xorm:"notnull default(0)"`    //访问数
-	Series       string   `json:"series"`                         //系列
-	Tags         []string `xorm:"json" json:"tags"`               //标签
-	Length       string   `json:"length"`                         //时长
-	MagnetLinks  []string `json:"-"`                              //磁链
-	Uncensored   bool     `json:"uncensored"`                     //有码,无码
+	Model         `xorm:"extends" json:"-"`
+	FindNo        string       `json:"-"`                              //查找号
+	Bangumi       string       `xorm:"bangumi" json:"bangumi"`         //番組
+	Intro         string       `xorm:"varchar(2048)" json:"intro"`     //简介
+	Alias         []string     `xorm:"json" json:"alias"`              //别名，片名
+	ThumbHash     string       `xorm:"thumb_hash" json:"thumb_hash"`   //缩略图
+	PosterHash    string       `xorm:"poster_hash" json:"poster_hash"` //海报地址
+	PreviewHash   string       `json:"preview_hash"`                   //预览片段
+	SourceHash    string       `xorm:"source_hash" json:"source_hash"` //原片地址
+	M3U8Hash      string       `xorm:"m3u8_hash" json:"m3u8_hash"`     //切片地址
+	Key           string       `json:"-"`                              //秘钥
+	M3U8          string       `xorm:"m3u8" json:"-"`                  //M3U8名
+	MPD           string       `xorm:"mpd" json:"mpd"`                 //MPD名
+	SliceFormat   string       `json:"slice_format"`                   //切片格式:hls,fmp4,dash,cmaf
+	Trickplay     string       `json:"trickplay"`                      //进度条预览vtt
+	Renditions    []*Rendition `xorm:"json" json:"renditions"`         //多码率切片
+	AudioLanguage []string     `xorm:"json" json:"audio_language"`     //音轨语言
+	Role          []string     `xorm:"json" json:"role"`               //主演
+	Director      string       `json:"-"`                              //导演
+	Systematics   string       `json:"-"`                              //分级
+	Season        string       `json:"-"`                              //季
+	TotalEpisode  string       `json:"-"`                              //总集数
+	Episode       string       `json:"-"`                              //集数
+	Producer      string       `json:"-"`                              //生产商
+	Publisher     string       `json:"-"`                              //发行商
+	Type          string       `json:"-"`                              //类型：film，FanDrama
+	Format        string       `json:"format"`                         //输出格式：3D，2D,VR(VR格式：Half-SBS：左右半宽,Half-OU：上下半高,SBS：左右全宽)
+	Language      string       `json:"-"`                              //语言
+	Caption       string       `json:"-"`                              //字幕
+	Group         string       `json:"-"`                              //分组
+	Index         string       `json:"-"`                              //索引
+	Date          string       `json:"-"`                              //发行日期
+	Sharpness     string       `json:"sharpness"`                      //清晰度
+	Visit         uint64       `json:"-" xorm:"notnull default(0)"`    //访问数
+	Series        string       `json:"series"`                         //系列
+	Tags          []string     `xorm:"json" json:"tags"`               //标签
+	Length        string       `json:"length"`                         //时长
+	MagnetLinks   []string     `json:"-"`                              //磁链
+	Uncensored    bool         `json:"uncensored"`                     //有码,无码
+	Providers     int          `xorm:"notnull default(0)" json:"-"`    //提供者数
+	ProviderCheck *time.Time   `xorm:"provider_check" json:"-"`        //提供者检查时间
+	ProviderSeen  *time.Time   `xorm:"provider_seen" json:"-"`         //提供者最后发现时间
 }
 
 // GetID ...
@@ -95,6 +105,12 @@ func AllVideos(session *xorm.Session, limit int, start ...int) (videos *[]*Video
 	return videos, nil
 }
 
+// UpdateVideoProviders ...
+func UpdateVideoProviders(session *xorm.Session, video *Video) (e error) {
+	_, e = MustSession(session).ID(video.ID).Cols("providers", "provider_check", "provider_seen").Update(video)
+	return e
+}
+
 // DeepFind ...
 func DeepFind(session *xorm.Session, s string, videos *[]*Video) (e error) {
 	s1 := strings.ReplaceAll(s, "-", "")
@@ -150,6 +166,7 @@ func AddOrUpdateVideo(session *xorm.Session, video *Video, checkFn ...func(sessi
 		parseStr(&video.SourceHash, tmp.SourceHash)
 		parseStr(&video.PosterHash, tmp.PosterHash)
 		parseStr(&video.ThumbHash, tmp.ThumbHash)
+		parseStr(&video.PreviewHash, tmp.PreviewHash)
 		parseStr(&video.Sharpness, tmp.Sharpness)
 		i, e := session.Clone().ID(video.ID).Update(video)
 		log.Infof("updated(%d): %+v", i, tmp)
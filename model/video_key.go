@@ -0,0 +1,119 @@
+package model
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/xormsharp/xorm"
+)
+
+// VideoKey 切片的加密秘钥,秘钥由主秘钥加密后保存
+type VideoKey struct {
+	Model    `xorm:"extends"`
+	KeyID    string `xorm:"key_id" json:"key_id"`               //秘钥ID(m3u8中的URI)
+	Checksum string `xorm:"default() checksum" json:"checksum"` //源文件sum值
+	Relate   string `xorm:"default()" json:"relate"`            //关联信息
+	Key      string `xorm:"varchar(128)" json:"-"`              //加密后的秘钥
+	IV       string `xorm:"iv" json:"iv"`                       //初始向量
+	Rotate   int    `xorm:"notnull default(0)" json:"rotate"`   //轮换次数
+	Current  bool   `xorm:"notnull default(0)" json:"current"`  //当前使用的秘钥
+}
+
+func init() {
+	RegisterTable(VideoKey{})
+}
+
+// FindVideoKey find the key by the key id
+func FindVideoKey(session *xorm.Session, keyID string) (key *VideoKey, e error) {
+	key = new(VideoKey)
+	b, e := MustSession(session).Where("key_id = ?", keyID).Get(key)
+	if e != nil {
+		return nil, e
+	}
+	if !b {
+		return nil, errors.New("video key not found")
+	}
+	return key, nil
+}
+
+// AllVideoKey the keys of checksum,the latest rotated key is the first
+func AllVideoKey(session *xorm.Session, checksum string) (keys *[]*VideoKey, e error) {
+	keys = new([]*VideoKey)
+	e = MustSession(session).Where("checksum = ?", checksum).Desc("rotate").Find(keys)
+	return
+}
+
+// AddVideoKey add the key as the current key of the checksum,the old keys are kept to decrypt the old slices
+func AddVideoKey(session *xorm.Session, key *VideoKey) (e error) {
+	last := new(VideoKey)
+	found, e := session.Clone().Where("checksum = ?", key.Checksum).Desc("rotate").Get(last)
+	if e != nil {
+		return e
+	}
+	if found {
+		key.Rotate = last.Rotate + 1
+		//the version of the old keys is not checked,the update by the struct is limited to version 0
+		_, e = session.Clone().Exec("UPDATE video_key SET current = ? WHERE checksum = ?", false, key.Checksum)
+		if e != nil {
+			return e
+		}
+	}
+	key.Current = true
+	_, e = session.Clone().InsertOne(key)
+	return
+}
+
+// ImportVideoKey add the key imported from the other node as is,the key of the existing key id is kept
+func ImportVideoKey(session *xorm.Session, key *VideoKey) (e error) {
+	found, e := session.Clone().Where("key_id = ?", key.KeyID).Exist(new(VideoKey))
+	if e != nil || found {
+		return e
+	}
+	_, e = session.Clone().InsertOne(key)
+	return
+}
+
+// SealKey encrypt the key with the master key(aes-gcm)
+func SealKey(master []byte, key []byte) (string, error) {
+	gcm, e := masterGCM(master)
+	if e != nil {
+		return "", e
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return "", e
+	}
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, key, nil)), nil
+}
+
+// OpenKey decrypt the sealed key with the master key
+func OpenKey(master []byte, sealed string) ([]byte, error) {
+	gcm, e := masterGCM(master)
+	if e != nil {
+		return nil, e
+	}
+	data, e := hex.DecodeString(sealed)
+	if e != nil {
+		return nil, e
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("sealed key too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func masterGCM(master []byte) (cipher.AEAD, error) {
+	if len(master) == 0 {
+		return nil, errors.New("master key is empty")
+	}
+	sum := sha256.Sum256(master)
+	block, e := aes.NewCipher(sum[:])
+	if e != nil {
+		return nil, e
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSealKey ...
+func TestSealKey(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	sealed, e := SealKey([]byte("master"), key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	opened, e := OpenKey([]byte("master"), sealed)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(opened, key) {
+		t.Errorf("opened %x", opened)
+	}
+	if _, e := OpenKey([]byte("other"), sealed); e == nil {
+		t.Error("opened with the wrong master key")
+	}
+}
+
+// TestAddVideoKey ...
+func TestAddVideoKey(t *testing.T) {
+	eng, closer := testEngine(t, VideoKey{})
+	defer closer()
+	for _, id := range []string{"key0", "key1", "key2"} {
+		e := AddVideoKey(eng.Where(""), &VideoKey{KeyID: id, Checksum: "sum"})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	e := AddVideoKey(eng.Where(""), &VideoKey{KeyID: "other", Checksum: "other"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	keys, e := AllVideoKey(eng.Where(""), "sum")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(*keys) != 3 {
+		t.Fatal(len(*keys))
+	}
+	for i, key := range *keys {
+		if key.Rotate != 2-i || key.Current != (i == 0) {
+			t.Errorf("%+v", key)
+		}
+	}
+	other, e := FindVideoKey(eng.Where(""), "other")
+	if e != nil || !other.Current || other.Rotate != 0 {
+		t.Errorf("%+v %v", other, e)
+	}
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestVideoLanguage ...
+func TestVideoLanguage(t *testing.T) {
+	eng, closer := testEngine(t, Video{})
+	defer closer()
+	//the rows written before the audio languages keep the bare language
+	_, e := eng.Exec("INSERT INTO video (id, find_no, bangumi, language, version) VALUES (?, ?, ?, ?, ?)", "legacy", "ABP123", "ABP-123", "日语", 1)
+	if e != nil {
+		t.Fatal(e)
+	}
+	video, e := FindVideo(eng.Where(""), "ABP-123")
+	if e != nil {
+		t.Fatal(e)
+	}
+	video.AudioLanguage = []string{"jpn", "eng"}
+	e = AddOrUpdateVideo(eng.Where(""), video)
+	if e != nil {
+		t.Fatal(e)
+	}
+	video, e = FindVideo(eng.Where(""), "ABP-123")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if video.Language != "日语" || strings.Join(video.AudioLanguage, ",") != "jpn,eng" {
+		t.Errorf("%+v", video)
+	}
+}
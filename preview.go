@@ -0,0 +1,98 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// Preview the muted clip stitched from the excerpts of the video
+type Preview struct {
+	Clips    int     //the excerpts count
+	Duration float64 //seconds of each excerpt
+	Height   int
+	Format   string //mp4,webm
+}
+
+// DefaultPreview 5 excerpts of 2 seconds in 320P mp4
+var DefaultPreview = &Preview{
+	Clips:    5,
+	Duration: 2,
+	Height:   320,
+	Format:   "mp4",
+}
+
+// Points the start seconds of the excerpts evenly spaced in the duration,
+// one excerpt from the beginning if the video is shorter than the excerpts
+func (p *Preview) Points(duration float64) []float64 {
+	if p.Clips <= 0 || duration <= float64(p.Clips)*p.Duration {
+		return []float64{0}
+	}
+	var points []float64
+	for i := 0; i < p.Clips; i++ {
+		start := duration*float64(i+1)/float64(p.Clips+1) - p.Duration/2
+		if start < 0 {
+			start = 0
+		}
+		points = append(points, start)
+	}
+	return points
+}
+
+// Args the ffmpeg args to stitch the excerpts at points of file into output
+func (p *Preview) Args(file string, points []float64, output string) []string {
+	var args []string
+	var filters, inputs []string
+	for i, point := range points {
+		args = append(args,
+			"-ss", strconv.FormatFloat(point, 'f', 3, 64),
+			"-t", strconv.FormatFloat(p.Duration, 'f', 3, 64),
+			"-i", file,
+		)
+		filters = append(filters, fmt.Sprintf("[%d:v]scale=-2:%d,setsar=1,fps=25[v%d]", i, p.Height, i))
+		inputs = append(inputs, fmt.Sprintf("[v%d]", i))
+	}
+	filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[out]", strings.Join(inputs, ""), len(points)))
+	args = append([]string{"-y"}, args...)
+	args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", "[out]", "-an")
+	if p.Format == "webm" {
+		args = append(args, "-c:v", "libvpx-vp9", "-b:v", "0", "-crf", "40", "-deadline", "good")
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p", "-movflags", "+faststart")
+	}
+	return append(args, output)
+}
+
+// Output the preview of the name under dir
+func (p *Preview) Output(dir string, name string) string {
+	return filepath.Join(dir, name+".preview."+p.Format)
+}
+
+// Generate generate the preview of file named name under dir,returns the preview file
+func (p *Preview) Generate(ctx context.Context, file string, dir string, name string) (string, error) {
+	format, e := cmd.FFProbeStreamFormat(file)
+	if e != nil {
+		return "", e
+	}
+	if format.Video() == nil {
+		return "", errors.New("video stream not found")
+	}
+	duration, _ := strconv.ParseFloat(format.Format.Duration, 64)
+	e = os.MkdirAll(dir, os.ModePerm)
+	if e != nil {
+		return "", e
+	}
+	output := p.Output(dir, name)
+	e = FFMpegRun(ctx, p.Args(file, p.Points(duration), output)...)
+	if e != nil {
+		os.Remove(output)
+		return "", e
+	}
+	return output, nil
+}
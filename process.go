@@ -25,7 +25,11 @@ type Process struct {
 	*Thread
 	//taskMutex *sync.RWMutex
 	//tasks     map[string]*Task
-	cb chan ProcessCaller
+	cb           chan ProcessCaller
+	Transcode    *TranscodeProfile //transcode the incompatible sources before slicing,nil to skip
+	TranscodeDir string
+	Frame        *FrameExtract //extract the poster and the thumb of the sources,nil to skip
+	FrameDir     string
 	//workspace   string
 	//path        string
 	//moves       map[string]string
@@ -52,10 +56,13 @@ func (p *Process) push(cb interface{}) error {
 }
 
 // NewProcess ...
-func NewProcess() *Process {
+func NewProcess(args ...ProcessArgs) *Process {
 	process := &Process{}
 	process.Thread = NewThread()
 	process.cb = make(chan ProcessCaller)
+	for _, argFn := range args {
+		argFn(process)
+	}
 	return process
 }
 
@@ -226,25 +233,6 @@ func (p *Process) getFiles(ws string) (files []string) {
 	return append(files, ws)
 }
 
-func (p *Process) skip(format *cmd.StreamFormat) bool {
-	//if !p.skipConvert {
-	//	log.Info("noskip")
-	//	return p.skipConvert
-	//}
-	video := format.Video()
-	audio := format.Audio()
-	if audio == nil || video == nil {
-		log.Info("isMedia")
-		return true
-	}
-	if video.CodecName != "h264" || audio.CodecName != "aac" {
-		log.Info("isMedia")
-		return true
-	}
-	log.Info("noskip")
-	return false
-}
-
 func moveSuccess(file string) (e error) {
 	dir, name := filepath.Split(file)
 	newPath := filepath.Join(dir, "success")
@@ -0,0 +1,178 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+)
+
+// ProgressKind ...
+type ProgressKind string
+
+// ProgressKindAdd ...
+const ProgressKindAdd ProgressKind = "add"
+
+// ProgressKindPin ...
+const ProgressKindPin ProgressKind = "pin"
+
+// DefaultProgressInterval ...
+const DefaultProgressInterval = 5 * time.Second
+
+// ProgressEvent ...
+type ProgressEvent struct {
+	Kind   ProgressKind `json:"kind"`
+	Node   string       `json:"node"`
+	Target string       `json:"target"` //file or dir added,hash pinned
+	Name   string       `json:"name"`   //current file of the add
+	Hash   string       `json:"hash"`   //hash of the finished file
+	Bytes  int64        `json:"bytes"`  //bytes added
+	Total  int64        `json:"total"`  //total bytes of the add
+	Blocks int          `json:"blocks"` //blocks fetched of the pin
+	Done   bool         `json:"done"`
+}
+
+// ProgressSink receive the progress events from the api thread
+type ProgressSink interface {
+	Progress(event *ProgressEvent)
+}
+
+// ProgressFunc ...
+type ProgressFunc func(event *ProgressEvent)
+
+// Progress ...
+func (fn ProgressFunc) Progress(event *ProgressEvent) {
+	fn(event)
+}
+
+// LogProgress write the progress events to log
+func LogProgress() ProgressSink {
+	return ProgressFunc(func(event *ProgressEvent) {
+		log.With("kind", event.Kind, "node", event.Node, "target", event.Target, "name", event.Name,
+			"bytes", event.Bytes, "total", event.Total, "blocks", event.Blocks, "done", event.Done).Info("progress")
+	})
+}
+
+// progressThrottle limit the events sent to sink in the interval,the done event is always sent
+type progressThrottle struct {
+	sink     ProgressSink
+	interval time.Duration
+	last     time.Time
+}
+
+func (t *progressThrottle) progress(event *ProgressEvent) {
+	if t.sink == nil {
+		return
+	}
+	now := time.Now()
+	if !event.Done && now.Sub(t.last) < t.interval {
+		return
+	}
+	t.last = now
+	t.sink.Progress(event)
+}
+
+func (api *API) throttle() *progressThrottle {
+	return &progressThrottle{
+		sink:     api.progress,
+		interval: api.ProgressInterval,
+	}
+}
+
+// addProgress receive the add events of an add on node,returns the option and the wait func after add
+func (api *API) addProgress(node *APINode, target string, total int64) (options.UnixfsAddOption, func()) {
+	if api.progress == nil {
+		return func(settings *options.UnixfsAddSettings) error { return nil }, func() {}
+	}
+	events := make(chan interface{})
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		t := api.throttle()
+		event := &ProgressEvent{
+			Kind:   ProgressKindAdd,
+			Node:   node.Addr,
+			Target: target,
+			Total:  total,
+		}
+		for v := range events {
+			add, b := v.(*iface.AddEvent)
+			if !b {
+				continue
+			}
+			event.Name = add.Name
+			if add.Path != nil {
+				event.Hash = add.Path.Cid().String()
+			}
+			if add.Bytes > 0 {
+				event.Bytes = add.Bytes
+			}
+			t.progress(event)
+		}
+		event.Done = true
+		t.progress(event)
+	}()
+	return func(settings *options.UnixfsAddSettings) error {
+			settings.Progress = true
+			settings.Events = events
+			return nil
+		}, func() {
+			close(events)
+			<-done
+		}
+}
+
+// PinAdd pin the hash recursive on the node of ipapi with the progress of blocks fetched,
+// ipapi is the one given to the api caller,it is safe to pin from the other threads with it
+func PinAdd(api *API, ipapi *httpapi.HttpApi, hash string) error {
+	id := ""
+	if node := api.nodeOf(ipapi); node != nil {
+		id = node.Addr
+	}
+	return pinAdd(api.Context(), ipapi, id, hash, api.throttle())
+}
+
+func pinAdd(ctx context.Context, ipapi *httpapi.HttpApi, id string, hash string, t *progressThrottle) error {
+	resp, e := ipapi.Request("pin/add", hash).
+		Option("recursive", true).
+		Option("progress", t.sink != nil).Send(ctx)
+	if e != nil {
+		return e
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	defer resp.Close()
+
+	event := &ProgressEvent{
+		Kind:   ProgressKindPin,
+		Node:   id,
+		Target: hash,
+	}
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out struct {
+			Pins     []string
+			Progress int
+		}
+		e := dec.Decode(&out)
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return e
+		}
+		if out.Pins != nil {
+			event.Done = true
+		}
+		if out.Progress > 0 {
+			event.Blocks = out.Progress
+		}
+		t.progress(event)
+	}
+	return nil
+}
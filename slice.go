@@ -37,9 +37,18 @@ type Slice struct {
 	SkipType    []interface{}
 	SkipExist   bool
 	SkipSlice   bool
+	Ladder      []Scale //slice the renditions with a master playlist,empty to slice one rendition
+	Encrypt     bool    //encrypt the slices with aes-128,a new key is generated on every slice
+	MasterKey   []byte  //seal the keys stored in the database
+	KeyURI      string  //the key uri prefix in the m3u8
+	Format      SliceFormat
+	Trickplay   *Trickplay //generate the seek previews,nil to skip
 	cb          chan SliceCaller
 }
 
+// SliceArgs ...
+type SliceArgs func(s *Slice)
+
 // Push ...
 func (s *Slice) Push(v interface{}) error {
 	return s.push(v)
@@ -66,13 +75,19 @@ func (s *Slice) push(cb interface{}) error {
 }
 
 // NewSlice ...
-func NewSlice() *Slice {
+func NewSlice(args ...SliceArgs) *Slice {
 	output := os.TempDir()
-	return &Slice{
+	s := &Slice{
 		SliceOutput: output,
+		KeyURI:      DefaultKeyURI,
+		Format:      SliceFormatHLS,
 		cb:          make(chan SliceCaller),
 		Thread:      NewThread(),
 	}
+	for _, argFn := range args {
+		argFn(s)
+	}
+	return s
 }
 
 // Run ...
@@ -138,12 +153,13 @@ type unfinishedSlice struct {
 // SliceCallbackFunc ...
 type SliceCallbackFunc func(s *Slice, sa *cmd.SplitArgs, v interface{}) (e error)
 
-// SliceCall ...
-func SliceCall(file string, u *model.Unfinished, cb SliceCallbackFunc) (Stepper, SliceCaller) {
+// SliceCall slice the file,the converted captions are added as the subtitle renditions of the hls output
+func SliceCall(file string, u *model.Unfinished, cb SliceCallbackFunc, captions ...*Caption) (Stepper, SliceCaller) {
 	return StepperSlice, &sliceCall{
 		cb:         cb,
 		unfinished: u,
 		file:       file,
+		captions:   captions,
 	}
 }
 
@@ -160,13 +176,9 @@ func scaleStr(s Scale) string {
 	return fmt.Sprintf("%dP", scale(s))
 }
 
+// isMedia the audio is optional,the source without audio is sliced as the silent video
 func isMedia(format *cmd.StreamFormat) bool {
-	video := format.Video()
-	audio := format.Audio()
-	if audio == nil || video == nil {
-		return false
-	}
-	return true
+	return format.Video() != nil
 }
 
 // Call ...
@@ -175,19 +187,78 @@ func (c *sliceCall) Call(s *Slice) (e error) {
 	if e != nil {
 		return e
 	}
+	if len(c.captions) > 0 && !s.Format.IsDASH() {
+		e = sliceCaptions(sa, c.unfinished, c.captions)
+		if e != nil {
+			return e
+		}
+	}
+	if s.Trickplay != nil {
+		//the previews are optional,the slice is kept without them
+		e = sliceTrickplay(s, c.file, sa, c.unfinished)
+		if e != nil {
+			log.With("file", c.file).Error(e)
+		}
+	}
 	return c.cb(s, sa, c.unfinished)
 }
 
 func sliceVideo(slice *Slice, file string, u *model.Unfinished) (sa *cmd.SplitArgs, e error) {
+	if e := slice.checkMasterKey(); e != nil {
+		return nil, e
+	}
 	format, e := cmd.FFProbeStreamFormat(file)
 	if e != nil {
 		return nil, e
 	}
+	return SliceStream(slice, file, format, u)
+}
+
+// checkMasterKey the key is sealed after the slices are done,the slices are useless without the master key
+func (s *Slice) checkMasterKey() error {
+	if s.Encrypt && len(s.MasterKey) == 0 {
+		return errors.New("master key is empty")
+	}
+	return nil
+}
+
+// SliceStream slice the probed format of file by the format,the ladder and the encrypt of slice
+func SliceStream(slice *Slice, file string, format *cmd.StreamFormat, u *model.Unfinished) (sa *cmd.SplitArgs, e error) {
+	if e := slice.checkMasterKey(); e != nil {
+		return nil, e
+	}
 	if !isMedia(format) {
-		return nil, errors.New("format video/audio not found")
+		return nil, errors.New("format video not found")
 	}
 
 	u.Type = model.TypeSlice
+	if slice.Format == "" {
+		slice.Format = SliceFormatHLS
+	}
+	u.SliceFormat = string(slice.Format)
+	tracks := AudioTracks(format)
+	u.Language = AudioLanguages(tracks)
+	if slice.Format.IsDASH() {
+		if slice.Encrypt {
+			return nil, errors.New("encrypt is not supported by the dash output")
+		}
+		return sliceDASH(slice, file, format, u, tracks)
+	}
+	var key *HLSKey
+	if slice.Encrypt {
+		key, e = NewHLSKey()
+		if e != nil {
+			return nil, e
+		}
+	}
+	//the alternate audio renditions are referenced by the master playlist
+	if len(slice.Ladder) > 0 || len(tracks) > 1 {
+		return sliceLadder(slice, file, format, u, key, tracks)
+	}
+	//the split of cmd requires the audio stream
+	if key != nil || slice.Format != SliceFormatHLS || format.Audio() == nil {
+		return sliceSingle(slice, file, format, u, key)
+	}
 	s := slice.Scale
 	if s != 0 {
 		res := format.ResolutionInt()
@@ -209,6 +280,7 @@ type sliceCall struct {
 	cb         SliceCallbackFunc
 	unfinished *model.Unfinished
 	file       string
+	captions   []*Caption
 }
 
 var _ SliceCaller = &sliceCall{}
@@ -0,0 +1,88 @@
+package seed
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// AudioGroup the group id of the audio renditions in the master playlist
+const AudioGroup = "audio"
+
+// AudioTrack an audio stream of the source
+type AudioTrack struct {
+	Index    int //the order in the audio streams,0:a:<index>
+	Language string
+	Name     string
+	Default  bool
+}
+
+// AudioTracks the audio streams of the format,the name is the language with the order.
+// the first default stream is the default track,or the first stream if none is default
+func AudioTracks(format *cmd.StreamFormat) []*AudioTrack {
+	var tracks []*AudioTrack
+	def := -1
+	for _, s := range format.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		language := strings.ToLower(s.Tags.Language)
+		if language == "" {
+			language = UndefinedLanguage
+		}
+		i := len(tracks)
+		tracks = append(tracks, &AudioTrack{
+			Index:    i,
+			Language: language,
+			Name:     fmt.Sprintf("audio-%s-%d", language, i),
+			Default:  s.Disposition["default"] == 1 && def < 0,
+		})
+		if tracks[i].Default {
+			def = i
+		}
+	}
+	if def < 0 && len(tracks) > 0 {
+		tracks[0].Default = true
+	}
+	return tracks
+}
+
+// AudioLanguages the languages of the tracks,the undefined language is skipped
+func AudioLanguages(tracks []*AudioTrack) []string {
+	var languages []string
+	for _, t := range tracks {
+		if t.Language == UndefinedLanguage || containsString(languages, t.Language) {
+			continue
+		}
+		languages = append(languages, t.Language)
+	}
+	return languages
+}
+
+// audioArgs map the tracks to the i'th audio output streams
+func audioArgs(tracks []*AudioTrack) []string {
+	var args []string
+	for i, t := range tracks {
+		args = append(args,
+			"-map", fmt.Sprintf("0:a:%d", t.Index),
+			fmt.Sprintf("-c:a:%d", i), "aac",
+			fmt.Sprintf("-b:a:%d", i), fmt.Sprintf("%dK", ladderAudioBitRate),
+			fmt.Sprintf("-metadata:s:a:%d", i), "language="+t.Language,
+		)
+	}
+	return args
+}
+
+// audioStreams the var_stream_map entries of the alternate audio renditions
+func audioStreams(tracks []*AudioTrack) []string {
+	var streams []string
+	for i, t := range tracks {
+		s := fmt.Sprintf("a:%d,agroup:%s,language:%s,name:%s", i, AudioGroup, t.Language, t.Name)
+		if t.Default {
+			s += ",default:yes"
+		}
+		streams = append(streams, s)
+	}
+	return streams
+}
@@ -0,0 +1,194 @@
+package seed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+	"github.com/google/uuid"
+	"github.com/xormsharp/xorm"
+)
+
+// DefaultKeyURI the key uri prefix written into the m3u8,the key id is appended
+const DefaultKeyURI = "key://"
+
+// SliceEncryptArg encrypt the slices with aes-128,the keys are sealed with the master key before stored
+func SliceEncryptArg(master []byte) SliceArgs {
+	return func(s *Slice) {
+		s.Encrypt = true
+		s.MasterKey = master
+	}
+}
+
+// SliceKeyURIArg ...
+func SliceKeyURIArg(uri string) SliceArgs {
+	return func(s *Slice) {
+		s.KeyURI = uri
+	}
+}
+
+// HLSKey the aes-128 key of a slice
+type HLSKey struct {
+	ID  string
+	Key []byte
+	IV  []byte
+}
+
+// NewHLSKey generate a random key and iv
+func NewHLSKey() (*HLSKey, error) {
+	k := &HLSKey{
+		ID:  uuid.New().String(),
+		Key: make([]byte, 16),
+		IV:  make([]byte, 16),
+	}
+	if _, e := rand.Read(k.Key); e != nil {
+		return nil, e
+	}
+	if _, e := rand.Read(k.IV); e != nil {
+		return nil, e
+	}
+	return k, nil
+}
+
+// WriteKeyInfo write the key file and the ffmpeg key info file into dir,returns the key info path
+func (k *HLSKey) WriteKeyInfo(dir string, uri string) (string, error) {
+	keyFile := filepath.Join(dir, k.ID+".key")
+	e := ioutil.WriteFile(keyFile, k.Key, 0600)
+	if e != nil {
+		return "", e
+	}
+	info := filepath.Join(dir, k.ID+".keyinfo")
+	content := strings.Join([]string{uri + k.ID, keyFile, hex.EncodeToString(k.IV)}, "\n")
+	e = ioutil.WriteFile(info, []byte(content), 0600)
+	if e != nil {
+		os.Remove(keyFile)
+		return "", e
+	}
+	return info, nil
+}
+
+// RemoveKeyInfo ...
+func (k *HLSKey) RemoveKeyInfo(dir string) {
+	os.Remove(filepath.Join(dir, k.ID+".key"))
+	os.Remove(filepath.Join(dir, k.ID+".keyinfo"))
+}
+
+// VideoKey seal the key with master as the key record of the unfinished
+func (k *HLSKey) VideoKey(master []byte, u *model.Unfinished) (*model.VideoKey, error) {
+	sealed, e := model.SealKey(master, k.Key)
+	if e != nil {
+		return nil, e
+	}
+	return &model.VideoKey{
+		KeyID:    k.ID,
+		Checksum: u.Checksum,
+		Relate:   u.Relate,
+		Key:      sealed,
+		IV:       hex.EncodeToString(k.IV),
+	}, nil
+}
+
+// KeyInfoArgs add the key info file to the ffmpeg args before the output
+func KeyInfoArgs(args []string, info string) []string {
+	if info == "" || len(args) == 0 {
+		return args
+	}
+	last := len(args) - 1
+	v := append([]string{}, args[:last]...)
+	return append(v, "-hls_key_info_file", info, args[last])
+}
+
+// SingleArgs the ffmpeg args to slice one hls rendition as cmd.FFMpegSplitToM3U8
+func SingleArgs(file string, sa *cmd.SplitArgs, format SliceFormat) []string {
+	args := []string{"-y", "-i", file, "-strict", "-2", "-c:v", sa.Video, "-c:a", sa.Audio}
+	if sa.Scale != 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:%d", sa.Scale))
+	}
+	if format != SliceFormatFMP4 {
+		args = append(args, "-bsf:v", "h264_mp4toannexb")
+	}
+	args = append(args,
+		"-f", "hls",
+		"-hls_list_size", "0",
+		"-hls_time", fmt.Sprint(sa.HLSTime),
+	)
+	args = append(args, format.hlsSegmentArgs()...)
+	return append(args,
+		"-hls_segment_filename", filepath.Join(sa.Output, sa.SegmentFileName),
+		filepath.Join(sa.Output, sa.M3U8),
+	)
+}
+
+// sliceSingle slice one hls rendition with the key and the format
+func sliceSingle(slice *Slice, file string, format *cmd.StreamFormat, u *model.Unfinished, key *HLSKey) (*cmd.SplitArgs, error) {
+	output, e := filepath.Abs(filepath.Join(slice.SliceOutput, uuid.New().String()))
+	if e != nil {
+		return nil, e
+	}
+	e = os.MkdirAll(output, os.ModePerm)
+	if e != nil {
+		return nil, e
+	}
+	sa := &cmd.SplitArgs{
+		StreamFormat:    format,
+		Output:          output,
+		Video:           "libx264",
+		Audio:           "aac",
+		M3U8:            "media.m3u8",
+		SegmentFileName: slice.Format.SegmentFile(),
+		HLSTime:         10,
+	}
+	res := int64(format.ResolutionInt())
+	if s := int64(slice.Scale); s != 0 && s < res {
+		sa.Scale = s
+		u.Sharpness = scaleStr(slice.Scale)
+	} else {
+		u.Sharpness = format.Resolution() + "P"
+	}
+	if sa.Scale == 0 && format.Video().CodecName == "h264" {
+		sa.Video = "copy"
+	}
+	if audio := format.Audio(); audio != nil && audio.CodecName == "aac" {
+		sa.Audio = "copy"
+	}
+	e = slice.runEncrypted(SingleArgs(file, sa, slice.Format), u, key)
+	if e != nil {
+		return nil, e
+	}
+	u.M3U8 = sa.M3U8
+	u.SegmentFile = sa.SegmentFileName
+	return sa, nil
+}
+
+// runEncrypted run ffmpeg with the key info of key,the key is stored after the slices are done
+func (s *Slice) runEncrypted(args []string, u *model.Unfinished, key *HLSKey) error {
+	if key == nil {
+		return FFMpegRun(s.Context(), args...)
+	}
+	//the key file must not be written to the output,the output is added to ipfs
+	dir := os.TempDir()
+	info, e := key.WriteKeyInfo(dir, s.KeyURI)
+	if e != nil {
+		return e
+	}
+	defer key.RemoveKeyInfo(dir)
+	e = FFMpegRun(s.Context(), KeyInfoArgs(args, info)...)
+	if e != nil {
+		return e
+	}
+	vk, e := key.VideoKey(s.MasterKey, u)
+	if e != nil {
+		return e
+	}
+	u.Encrypt = true
+	u.Key = key.ID
+	return s.PushTo(DatabaseCallback(vk, func(database *Database, eng *xorm.Engine, v interface{}) (e error) {
+		return model.AddVideoKey(eng.Where(""), v.(*model.VideoKey))
+	}))
+}
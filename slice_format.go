@@ -0,0 +1,139 @@
+package seed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+	"github.com/google/uuid"
+)
+
+// SliceFormat the segment container and the manifests of the slice output
+type SliceFormat string
+
+// SliceFormatHLS mpeg-ts segments with m3u8
+const SliceFormatHLS SliceFormat = "hls"
+
+// SliceFormatFMP4 fmp4 segments with m3u8
+const SliceFormatFMP4 SliceFormat = "fmp4"
+
+// SliceFormatDASH fmp4 segments with mpd
+const SliceFormatDASH SliceFormat = "dash"
+
+// SliceFormatCMAF fmp4 segments with both mpd and m3u8
+const SliceFormatCMAF SliceFormat = "cmaf"
+
+// DefaultMPD the mpd name of the dash output
+const DefaultMPD = "manifest.mpd"
+
+// fmp4InitFile the init segment name of the fmp4 hls output
+const fmp4InitFile = "init.mp4"
+
+// SliceFormatArg ...
+func SliceFormatArg(format SliceFormat) SliceArgs {
+	return func(s *Slice) {
+		s.Format = format
+	}
+}
+
+// IsDASH the output is sliced by the dash muxer
+func (f SliceFormat) IsDASH() bool {
+	return f == SliceFormatDASH || f == SliceFormatCMAF
+}
+
+// SegmentFile the segment file name pattern of the format
+func (f SliceFormat) SegmentFile() string {
+	switch f {
+	case SliceFormatFMP4:
+		return "media-%05d.m4s"
+	case SliceFormatDASH, SliceFormatCMAF:
+		return "chunk-$RepresentationID$-$Number%05d$.m4s"
+	}
+	return "media-%05d.ts"
+}
+
+func (f SliceFormat) hlsSegmentArgs() []string {
+	if f == SliceFormatFMP4 {
+		return []string{"-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", fmp4InitFile}
+	}
+	return nil
+}
+
+// DASHArgs the ffmpeg args to slice file into the dash renditions under output,
+// the tracks are the audio streams of the source,no audio is mapped without tracks.
+// the cmaf format writes the m3u8 playlists of the same segments
+func DASHArgs(file string, output string, renditions []*model.Rendition, segTime int, format SliceFormat, tracks ...*AudioTrack) []string {
+	args := []string{"-y", "-i", file, "-filter_complex", ladderFilter(renditions)}
+	for i, r := range renditions {
+		args = append(args, ladderVideoArgs(i, r)...)
+	}
+	sets := "id=0,streams=v"
+	switch {
+	case len(tracks) > 1:
+		args = append(args, audioArgs(tracks)...)
+	case len(tracks) == 1:
+		args = append(args,
+			"-map", fmt.Sprintf("0:a:%d", tracks[0].Index),
+			"-c:a", "aac",
+			"-b:a", fmt.Sprintf("%dK", ladderAudioBitRate),
+		)
+	}
+	if len(tracks) > 0 {
+		sets += " id=1,streams=a"
+	}
+	args = append(args,
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", segTime),
+		"-f", "dash",
+		"-seg_duration", fmt.Sprint(segTime),
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-init_seg_name", "init-$RepresentationID$.m4s",
+		"-media_seg_name", format.SegmentFile(),
+		"-adaptation_sets", sets,
+	)
+	if format == SliceFormatCMAF {
+		args = append(args, "-hls_playlist", "1")
+	}
+	return append(args, filepath.Join(output, DefaultMPD))
+}
+
+// sliceDASH slice the file with the dash muxer,the renditions are the ladder or the scale
+func sliceDASH(slice *Slice, file string, format *cmd.StreamFormat, u *model.Unfinished, tracks []*AudioTrack) (*cmd.SplitArgs, error) {
+	renditions, e := sliceRenditions(slice, format)
+	if e != nil {
+		return nil, e
+	}
+	output, e := filepath.Abs(filepath.Join(slice.SliceOutput, uuid.New().String()))
+	if e != nil {
+		return nil, e
+	}
+	e = os.MkdirAll(output, os.ModePerm)
+	if e != nil {
+		return nil, e
+	}
+	sa := &cmd.SplitArgs{
+		StreamFormat:    format,
+		Output:          output,
+		Video:           "libx264",
+		Audio:           "aac",
+		SegmentFileName: slice.Format.SegmentFile(),
+		HLSTime:         10,
+	}
+	e = FFMpegRun(slice.Context(), DASHArgs(file, output, renditions, sa.HLSTime, slice.Format, tracks...)...)
+	if e != nil {
+		return nil, e
+	}
+	u.MPD = DefaultMPD
+	if slice.Format == SliceFormatCMAF {
+		sa.M3U8 = MasterM3U8
+		u.M3U8 = MasterM3U8
+	}
+	u.SegmentFile = sa.SegmentFileName
+	u.Sharpness = renditions[len(renditions)-1].Sharpness
+	if len(renditions) > 1 {
+		u.Renditions = renditions
+	}
+	return sa, nil
+}
@@ -0,0 +1,232 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+	"github.com/google/uuid"
+)
+
+// MasterM3U8 the master playlist name of the ladder slice
+const MasterM3U8 = "master.m3u8"
+
+// DefaultLadder ...
+var DefaultLadder = []Scale{LowScale, MiddleScale, HighScale}
+
+// ladderBitRate the video bit rate(K) of the scale
+var ladderBitRate = map[Scale]int64{
+	240:  400,
+	360:  800,
+	480:  1200,
+	720:  2500,
+	1080: 5000,
+	1440: 9000,
+	2160: 16000,
+}
+
+// ladderAudioBitRate ...
+const ladderAudioBitRate = 128
+
+// SliceLadderArg slice the video into the renditions of ladder with a master playlist
+func SliceLadderArg(ladder ...Scale) SliceArgs {
+	return func(s *Slice) {
+		if len(ladder) == 0 {
+			ladder = DefaultLadder
+		}
+		s.Ladder = ladder
+	}
+}
+
+// LadderRenditions the renditions of ladder,the scales above the source height are dropped
+func LadderRenditions(ladder []Scale, height int64) []*model.Rendition {
+	var scales []Scale
+	for _, s := range ladder {
+		if int64(s) <= height {
+			scales = append(scales, s)
+		}
+	}
+	if len(scales) == 0 {
+		scales = append(scales, Scale(height))
+	}
+	sort.Slice(scales, func(i, j int) bool {
+		return scales[i] < scales[j]
+	})
+	var renditions []*model.Rendition
+	for i, s := range scales {
+		if i > 0 && scales[i-1] == s {
+			continue
+		}
+		rate, b := ladderBitRate[s]
+		if !b {
+			rate = int64(s) * 5
+		}
+		sharpness := fmt.Sprintf("%dP", s)
+		renditions = append(renditions, &model.Rendition{
+			Sharpness: sharpness,
+			BitRate:   rate,
+			M3U8:      sharpness + "/media.m3u8",
+		})
+	}
+	return renditions
+}
+
+// ladderFilter split the video and scale to the renditions as [v<i>out]
+func ladderFilter(renditions []*model.Rendition) string {
+	var filters []string
+	split := fmt.Sprintf("[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		split += fmt.Sprintf("[v%d]", i)
+	}
+	filters = append(filters, split)
+	for i, r := range renditions {
+		filters = append(filters, fmt.Sprintf("[v%d]scale=-2:%s[v%dout]", i, strings.TrimSuffix(r.Sharpness, "P"), i))
+	}
+	return strings.Join(filters, ";")
+}
+
+// ladderVideoArgs the encode args of the i'th rendition
+func ladderVideoArgs(i int, r *model.Rendition) []string {
+	return []string{
+		"-map", fmt.Sprintf("[v%dout]", i),
+		fmt.Sprintf("-c:v:%d", i), "libx264",
+		fmt.Sprintf("-b:v:%d", i), fmt.Sprintf("%dK", r.BitRate),
+		fmt.Sprintf("-maxrate:v:%d", i), fmt.Sprintf("%dK", r.BitRate*3/2),
+		fmt.Sprintf("-bufsize:v:%d", i), fmt.Sprintf("%dK", r.BitRate*2),
+	}
+}
+
+// LadderArgs the ffmpeg args to slice file into the hls renditions under output,
+// the tracks are the audio streams of the source,no audio is mapped without tracks.
+// the tracks are sliced as the alternate audio renditions if there are more than one track
+func LadderArgs(file string, output string, renditions []*model.Rendition, hlsTime int, format SliceFormat, tracks ...*AudioTrack) []string {
+	args := []string{"-y", "-i", file, "-filter_complex", ladderFilter(renditions)}
+	var streams []string
+	for i, r := range renditions {
+		args = append(args, ladderVideoArgs(i, r)...)
+		switch {
+		case len(tracks) > 1:
+			streams = append(streams, fmt.Sprintf("v:%d,agroup:%s,name:%s", i, AudioGroup, r.Sharpness))
+		case len(tracks) == 1:
+			args = append(args,
+				"-map", fmt.Sprintf("0:a:%d", tracks[0].Index),
+				fmt.Sprintf("-c:a:%d", i), "aac",
+				fmt.Sprintf("-b:a:%d", i), fmt.Sprintf("%dK", ladderAudioBitRate),
+			)
+			streams = append(streams, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, r.Sharpness))
+		default:
+			streams = append(streams, fmt.Sprintf("v:%d,name:%s", i, r.Sharpness))
+		}
+	}
+	if len(tracks) > 1 {
+		args = append(args, audioArgs(tracks)...)
+		streams = append(streams, audioStreams(tracks)...)
+	}
+	//keyframes are aligned across the renditions to switch on the segment boundary
+	args = append(args,
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsTime),
+		"-f", "hls",
+		"-hls_time", fmt.Sprint(hlsTime),
+		"-hls_list_size", "0",
+		"-hls_playlist_type", "vod",
+	)
+	args = append(args, format.hlsSegmentArgs()...)
+	args = append(args,
+		"-hls_segment_filename", filepath.Join(output, "%v", format.SegmentFile()),
+		"-master_pl_name", MasterM3U8,
+		"-var_stream_map", strings.Join(streams, " "),
+		filepath.Join(output, "%v", "media.m3u8"),
+	)
+	return args
+}
+
+// FFMpegRun run ffmpeg with the args,the args are passed as is
+func FFMpegRun(ctx context.Context, args ...string) error {
+	c := cmd.NewFFMpeg()
+	c.Args = args
+	fctx := cmd.FFmpegContext()
+	info := make(chan string, 1024)
+	done := make(chan error, 1)
+	go func() {
+		fctx.Add(1)
+		done <- c.RunContext(fctx, info)
+	}()
+	for {
+		select {
+		case e := <-done:
+			return e
+		case v := <-info:
+			log.With("status", "process").Debug(v)
+		case <-ctx.Done():
+			fctx.Cancel()
+			<-done
+			return ctx.Err()
+		}
+	}
+}
+
+// sliceRenditions the renditions of the ladder,or the scale if the ladder is not set
+func sliceRenditions(slice *Slice, format *cmd.StreamFormat) ([]*model.Rendition, error) {
+	video := format.Video()
+	if video == nil || video.Height == nil {
+		return nil, errors.New("video height not found")
+	}
+	ladder := slice.Ladder
+	if len(ladder) == 0 && slice.Scale != 0 {
+		ladder = []Scale{slice.Scale}
+	}
+	return LadderRenditions(ladder, *video.Height), nil
+}
+
+// sliceLadder slice the file into the renditions of ladder and the audio tracks under one directory,the renditions share the key
+func sliceLadder(slice *Slice, file string, format *cmd.StreamFormat, u *model.Unfinished, key *HLSKey, tracks []*AudioTrack) (*cmd.SplitArgs, error) {
+	renditions, e := sliceRenditions(slice, format)
+	if e != nil {
+		return nil, e
+	}
+	output, e := filepath.Abs(filepath.Join(slice.SliceOutput, uuid.New().String()))
+	if e != nil {
+		return nil, e
+	}
+	var dirs []string
+	for _, r := range renditions {
+		dirs = append(dirs, r.Sharpness)
+	}
+	if len(tracks) > 1 {
+		for _, t := range tracks {
+			dirs = append(dirs, t.Name)
+		}
+	}
+	for _, dir := range dirs {
+		e = os.MkdirAll(filepath.Join(output, dir), os.ModePerm)
+		if e != nil {
+			return nil, e
+		}
+	}
+	sa := &cmd.SplitArgs{
+		StreamFormat:    format,
+		Output:          output,
+		Video:           "libx264",
+		Audio:           "aac",
+		M3U8:            MasterM3U8,
+		SegmentFileName: slice.Format.SegmentFile(),
+		HLSTime:         10,
+	}
+	e = slice.runEncrypted(LadderArgs(file, output, renditions, sa.HLSTime, slice.Format, tracks...), u, key)
+	if e != nil {
+		return nil, e
+	}
+	u.M3U8 = MasterM3U8
+	u.SegmentFile = sa.SegmentFileName
+	u.Sharpness = renditions[len(renditions)-1].Sharpness
+	if len(renditions) > 1 {
+		u.Renditions = renditions
+	}
+	return sa, nil
+}
@@ -2,6 +2,10 @@ package seed_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/glvd/seed"
@@ -38,3 +42,216 @@ func TestNewSlice(t *testing.T) {
 	fmt.Println("waiting db end")
 	fmt.Println("db end")
 }
+
+// TestLadderRenditions ...
+func TestLadderRenditions(t *testing.T) {
+	renditions := seed.LadderRenditions([]seed.Scale{seed.HighScale, seed.LowScale, seed.MiddleScale}, 720)
+	if len(renditions) != 2 || renditions[0].Sharpness != "480P" || renditions[1].Sharpness != "720P" ||
+		renditions[1].M3U8 != "720P/media.m3u8" {
+		t.Errorf("%+v", renditions)
+	}
+	renditions = seed.LadderRenditions(seed.DefaultLadder, 360)
+	if len(renditions) != 1 || renditions[0].Sharpness != "360P" {
+		t.Errorf("%+v", renditions)
+	}
+
+	track := &seed.AudioTrack{Index: 0, Language: seed.UndefinedLanguage, Default: true}
+	args := strings.Join(seed.LadderArgs("in.mp4", "out", seed.LadderRenditions(seed.DefaultLadder, 1080), 10, seed.SliceFormatHLS, track), " ")
+	for _, v := range []string{
+		"[0:v]split=3[v0][v1][v2]",
+		"-master_pl_name master.m3u8",
+		"-map 0:a:0 -c:a:2 aac",
+		"v:0,a:0,name:480P v:1,a:1,name:720P v:2,a:2,name:1080P",
+	} {
+		if !strings.Contains(args, v) {
+			t.Errorf("%s not in %s", v, args)
+		}
+	}
+
+	//the source without audio
+	args = strings.Join(seed.LadderArgs("in.mp4", "out", seed.LadderRenditions(seed.DefaultLadder, 720), 10, seed.SliceFormatHLS), " ")
+	if !strings.Contains(args, "v:0,name:480P v:1,name:720P") || strings.Contains(args, "0:a:") || strings.Contains(args, "-c:a") {
+		t.Error(args)
+	}
+}
+
+// TestHLSKey ...
+func TestHLSKey(t *testing.T) {
+	key, e := seed.NewHLSKey()
+	if e != nil {
+		t.Fatal(e)
+	}
+	dir := os.TempDir()
+	info, e := key.WriteKeyInfo(dir, seed.DefaultKeyURI)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer key.RemoveKeyInfo(dir)
+	data, e := ioutil.ReadFile(info)
+	if e != nil {
+		t.Fatal(e)
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(lines) != 3 || lines[0] != seed.DefaultKeyURI+key.ID || len(lines[2]) != 32 {
+		t.Errorf("%q", lines)
+	}
+
+	args := seed.KeyInfoArgs([]string{"-y", "-i", "in.mp4", "out/media.m3u8"}, info)
+	if strings.Join(args, " ") != "-y -i in.mp4 -hls_key_info_file "+info+" out/media.m3u8" {
+		t.Errorf("%v", args)
+	}
+	vk, e := key.VideoKey([]byte("master"), &model.Unfinished{Checksum: "sum"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	plain, e := model.OpenKey([]byte("master"), vk.Key)
+	if e != nil || string(plain) != string(key.Key) || vk.KeyID != key.ID {
+		t.Errorf("%+v %v", vk, e)
+	}
+
+	//the file is not probed without the master key
+	_, caller := seed.SliceCall("not_exist.mp4", &model.Unfinished{}, nil)
+	e = caller.Call(seed.NewSlice(seed.SliceEncryptArg(nil)))
+	if e == nil || e.Error() != "master key is empty" {
+		t.Error(e)
+	}
+}
+
+// TestSliceFormat ...
+func TestSliceFormat(t *testing.T) {
+	renditions := seed.LadderRenditions(seed.DefaultLadder, 720)
+	args := strings.Join(seed.LadderArgs("in.mp4", "out", renditions, 10, seed.SliceFormatFMP4), " ")
+	if !strings.Contains(args, "-hls_segment_type fmp4") || !strings.Contains(args, "media-%05d.m4s") {
+		t.Error(args)
+	}
+	args = strings.Join(seed.DASHArgs("in.mp4", "out", renditions, 10, seed.SliceFormatCMAF), " ")
+	for _, v := range []string{"-f dash", "-hls_playlist 1", "-map [v1out]", "out/manifest.mpd"} {
+		if !strings.Contains(args, v) {
+			t.Errorf("%s not in %s", v, args)
+		}
+	}
+	args = strings.Join(seed.DASHArgs("in.mp4", "out", renditions, 10, seed.SliceFormatDASH, &seed.AudioTrack{Index: 0}), " ")
+	if strings.Contains(args, "-hls_playlist") || !strings.Contains(args, "-map 0:a:0") ||
+		!strings.Contains(args, "id=0,streams=v id=1,streams=a") {
+		t.Error(args)
+	}
+
+	//the source without audio
+	args = strings.Join(seed.DASHArgs("in.mp4", "out", renditions, 10, seed.SliceFormatDASH), " ")
+	if strings.Contains(args, "0:a:") || strings.Contains(args, "-c:a") || strings.Contains(args, "streams=a") {
+		t.Error(args)
+	}
+}
+
+// TestAudioTracks ...
+func TestAudioTracks(t *testing.T) {
+	format := &cmd.StreamFormat{Streams: []cmd.Stream{
+		{CodecType: "video"},
+		{CodecType: "audio", Tags: cmd.StreamTags{Language: "JPN"}},
+		{CodecType: "audio", Tags: cmd.StreamTags{Language: "eng"}, Disposition: map[string]int64{"default": 1}},
+		{CodecType: "audio", Disposition: map[string]int64{"default": 1}},
+	}}
+	//only the first default stream is the default track
+	tracks := seed.AudioTracks(format)
+	if len(tracks) != 3 || tracks[0].Language != "jpn" || tracks[0].Default || !tracks[1].Default || tracks[2].Default ||
+		tracks[2].Language != seed.UndefinedLanguage || tracks[2].Name != "audio-und-2" {
+		t.Errorf("%+v", tracks)
+	}
+	if languages := seed.AudioLanguages(tracks); strings.Join(languages, ",") != "jpn,eng" {
+		t.Error(languages)
+	}
+
+	args := strings.Join(seed.LadderArgs("in.mp4", "out", seed.LadderRenditions(seed.DefaultLadder, 720), 10, seed.SliceFormatHLS, tracks...), " ")
+	for _, v := range []string{
+		"-map 0:a:1 -c:a:1 aac",
+		"v:0,agroup:audio,name:480P v:1,agroup:audio,name:720P a:0,agroup:audio,language:jpn,name:audio-jpn-0 a:1,agroup:audio,language:eng,name:audio-eng-1,default:yes",
+	} {
+		if !strings.Contains(args, v) {
+			t.Errorf("%s not in %s", v, args)
+		}
+	}
+	if strings.Contains(args, "-map a:0") || strings.Count(args, "default:yes") != 1 {
+		t.Error(args)
+	}
+}
+
+// TestTrickplay ...
+func TestTrickplay(t *testing.T) {
+	trickplay := &seed.Trickplay{Interval: 10, Width: 160, Columns: 2, Rows: 2, Format: "jpg"}
+	w, h := trickplay.Size(1920, 1080)
+	if w != 160 || h != 90 {
+		t.Error(w, h)
+	}
+	args := strings.Join(trickplay.Args("in.mp4", "out", w, h), " ")
+	if !strings.Contains(args, "-vf fps=1/10,scale=160:90,tile=2x2") || !strings.HasSuffix(args, "out/sprite-%03d.jpg") {
+		t.Error(args)
+	}
+	vtt := trickplay.VTT(45.5, w, h)
+	for _, v := range []string{
+		"WEBVTT\n\n00:00:00.000 --> 00:00:10.000\nsprite-001.jpg#xywh=0,0,160,90\n",
+		"00:00:30.000 --> 00:00:40.000\nsprite-001.jpg#xywh=160,90,160,90\n",
+		"00:00:40.000 --> 00:00:45.500\nsprite-002.jpg#xywh=0,0,160,90\n",
+	} {
+		if !strings.Contains(vtt, v) {
+			t.Errorf("%s not in %s", v, vtt)
+		}
+	}
+}
+
+// TestPreview ...
+func TestPreview(t *testing.T) {
+	preview := seed.DefaultPreview
+	points := preview.Points(600)
+	if len(points) != 5 || points[0] != 99 || points[4] != 499 {
+		t.Error(points)
+	}
+	if points := preview.Points(8); len(points) != 1 || points[0] != 0 {
+		t.Error(points)
+	}
+	args := strings.Join(preview.Args("in.mp4", []float64{10, 20}, "out.mp4"), " ")
+	for _, v := range []string{
+		"-y -ss 10.000 -t 2.000 -i in.mp4 -ss 20.000 -t 2.000 -i in.mp4",
+		"[0:v]scale=-2:320,setsar=1,fps=25[v0];[1:v]scale=-2:320,setsar=1,fps=25[v1];[v0][v1]concat=n=2:v=1:a=0[out]",
+		"-map [out] -an -c:v libx264",
+	} {
+		if !strings.Contains(args, v) {
+			t.Errorf("%s not in %s", v, args)
+		}
+	}
+	if output := preview.Output("dir", "abc"); output != "dir/abc.preview.mp4" {
+		t.Error(output)
+	}
+}
+
+// TestSliceStream ...
+func TestSliceStream(t *testing.T) {
+	height := int64(720)
+	//the source without audio
+	format := &cmd.StreamFormat{Streams: []cmd.Stream{{CodecType: "video", CodecName: "h264", Height: &height}}}
+	dir, e := ioutil.TempDir("", "slice")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []seed.SliceFormat{seed.SliceFormatHLS, seed.SliceFormatFMP4, seed.SliceFormatDASH, seed.SliceFormatCMAF} {
+		for _, ladder := range [][]seed.Scale{nil, {480}} {
+			sli := seed.NewSlice(seed.SliceFormatArg(f))
+			sli.SliceOutput = dir
+			sli.Ladder = ladder
+			sli.BeforeRun(seed.NewSeed())
+			u := new(model.Unfinished)
+			//the file is missing,the error is returned by ffmpeg instead of the format check
+			_, e := seed.SliceStream(sli, filepath.Join(dir, "none.mp4"), format, u)
+			if e == nil || strings.Contains(e.Error(), "format video") || strings.Contains(e.Error(), "ffprobe") {
+				t.Errorf("%s%v:%v", f, ladder, e)
+			}
+			if u.Type != model.TypeSlice || u.SliceFormat != string(f) || len(u.Language) != 0 {
+				t.Errorf("%s%v:%+v", f, ladder, u)
+			}
+		}
+	}
+	format.Streams[0].CodecType = "audio"
+	if _, e := seed.SliceStream(seed.NewSlice(), "none.mp4", format, new(model.Unfinished)); e == nil {
+		t.Error("video not found")
+	}
+}
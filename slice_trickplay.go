@@ -0,0 +1,135 @@
+package seed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// trickplayDir the dir of the sprite sheets in the slice output
+const trickplayDir = "trickplay"
+
+// TrickplayVTT the webvtt thumbnails track in the trickplay dir
+const TrickplayVTT = "thumbnails.vtt"
+
+// Trickplay the sprite sheets of the seek previews
+type Trickplay struct {
+	Interval int //seconds between the thumbnails
+	Width    int //the thumbnail width,the height keeps the aspect
+	Columns  int
+	Rows     int
+	Format   string //jpg,png,webp
+}
+
+// DefaultTrickplay 160 width thumbnail every 10 seconds,10x10 in a sheet
+var DefaultTrickplay = &Trickplay{
+	Interval: 10,
+	Width:    160,
+	Columns:  10,
+	Rows:     10,
+	Format:   "jpg",
+}
+
+// SliceTrickplayArg generate the sprite sheets and the thumbnails track into the slice output
+func SliceTrickplayArg(trickplay *Trickplay) SliceArgs {
+	return func(s *Slice) {
+		if trickplay == nil {
+			trickplay = DefaultTrickplay
+		}
+		s.Trickplay = trickplay
+	}
+}
+
+// Size the thumbnail size of the video with the width and height,the height is even
+func (t *Trickplay) Size(width int64, height int64) (int, int) {
+	if width <= 0 || height <= 0 {
+		return t.Width, t.Width * 9 / 16 / 2 * 2
+	}
+	h := int(math.Round(float64(t.Width)*float64(height)/float64(width)/2)) * 2
+	if h < 2 {
+		h = 2
+	}
+	return t.Width, h
+}
+
+// SpriteFile the sprite sheet name pattern
+func (t *Trickplay) SpriteFile() string {
+	return "sprite-%03d." + t.Format
+}
+
+// Args the ffmpeg args to tile the thumbnails of file into the sprite sheets under output
+func (t *Trickplay) Args(file string, output string, width int, height int) []string {
+	args := []string{
+		"-y", "-i", file,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", t.Interval, width, height, t.Columns, t.Rows),
+	}
+	if t.Format == "jpg" || t.Format == "jpeg" {
+		args = append(args, "-q:v", "3")
+	}
+	return append(args, filepath.Join(output, t.SpriteFile()))
+}
+
+// VTT the webvtt thumbnails track of the duration,the cues refer to the sprite coordinates
+func (t *Trickplay) VTT(duration float64, width int, height int) string {
+	count := int(math.Ceil(duration / float64(t.Interval)))
+	tiles := t.Columns * t.Rows
+	lines := []string{"WEBVTT", ""}
+	for i := 0; i < count; i++ {
+		start := float64(i * t.Interval)
+		end := math.Min(float64((i+1)*t.Interval), duration)
+		idx := i % tiles
+		lines = append(lines,
+			vttTime(start)+" --> "+vttTime(end),
+			fmt.Sprintf(t.SpriteFile(), i/tiles+1)+fmt.Sprintf("#xywh=%d,%d,%d,%d", idx%t.Columns*width, idx/t.Columns*height, width, height),
+			"",
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// vttTime the webvtt timestamp hh:mm:ss.ttt
+func vttTime(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// sliceTrickplay generate the sprite sheets and the thumbnails track into the slice output,
+// the track is recorded as the trickplay of the unfinished
+func sliceTrickplay(slice *Slice, file string, sa *cmd.SplitArgs, u *model.Unfinished) error {
+	t := slice.Trickplay
+	if t.Interval <= 0 || t.Columns <= 0 || t.Rows <= 0 {
+		return fmt.Errorf("wrong trickplay:%+v", *t)
+	}
+	duration, _ := strconv.ParseFloat(sa.StreamFormat.Format.Duration, 64)
+	if duration <= 0 {
+		return fmt.Errorf("wrong duration:%s", sa.StreamFormat.Format.Duration)
+	}
+	var width, height int64
+	if video := sa.StreamFormat.Video(); video != nil && video.Width != nil && video.Height != nil {
+		width, height = *video.Width, *video.Height
+	}
+	w, h := t.Size(width, height)
+	output := filepath.Join(sa.Output, trickplayDir)
+	e := os.MkdirAll(output, os.ModePerm)
+	if e != nil {
+		return e
+	}
+	e = FFMpegRun(slice.Context(), t.Args(file, output, w, h)...)
+	if e != nil {
+		return e
+	}
+	e = ioutil.WriteFile(filepath.Join(output, TrickplayVTT), []byte(t.VTT(duration, w, h)), 0644)
+	if e != nil {
+		return e
+	}
+	u.Trickplay = trickplayDir + "/" + TrickplayVTT
+	return nil
+}
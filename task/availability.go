@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+// providerQueryEvent routing query event type of a provider response
+const providerQueryEvent = 4
+
+// DefaultNumProviders ...
+const DefaultNumProviders = 20
+
+// Availability probe the providers of the video content
+type Availability struct {
+	NumProviders int
+	Timeout      time.Duration
+	Unavailable  bool //only probe the videos checked as unavailable
+	Limit        int
+}
+
+// NewAvailability ...
+func NewAvailability() *Availability {
+	return &Availability{
+		NumProviders: DefaultNumProviders,
+		Timeout:      30 * time.Second,
+		Limit:        DefaultLimit,
+	}
+}
+
+// Task ...
+func (a *Availability) Task() *seed.Task {
+	return seed.NewTask(a)
+}
+
+// CallTask ...
+func (a *Availability) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperAPI, &availabilityCall{
+			num:         a.NumProviders,
+			timeout:     a.Timeout,
+			unavailable: a.Unavailable,
+			limit:       a.Limit,
+		})
+	}
+}
+
+type availabilityCall struct {
+	num         int
+	timeout     time.Duration
+	unavailable bool
+	limit       int
+}
+
+// Call ...
+func (c *availabilityCall) Call(a *seed.API, api *httpapi.HttpApi) error {
+	v := make(chan *model.Video)
+	e := a.PushTo(seed.DatabaseVideoCall(v, func(session *xorm.Session) *xorm.Session {
+		session = session.Where("m3u8_hash <> ?", "")
+		if c.unavailable {
+			session = session.And("providers = ?", 0).And("provider_check IS NOT NULL")
+		}
+		if c.limit > 0 {
+			session = session.Limit(c.limit)
+		}
+		return session
+	}))
+	if e != nil {
+		return e
+	}
+	var videos []*model.Video
+	for video := range v {
+		if video == nil {
+			break
+		}
+		videos = append(videos, video)
+	}
+
+	unavailable := 0
+	for _, video := range videos {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		ctx, cancel := context.WithTimeout(a.Context(), c.timeout)
+		count, e := FindProviders(ctx, api, video.M3U8Hash, c.num)
+		cancel()
+		if e != nil {
+			//the incomplete query is not recorded unless a provider is found
+			log.With("bangumi", video.Bangumi, "hash", video.M3U8Hash, "providers", count).Error(e)
+			if count == 0 {
+				continue
+			}
+		}
+		now := time.Now()
+		video.Providers = count
+		video.ProviderCheck = &now
+		if count > 0 {
+			video.ProviderSeen = &now
+		} else {
+			unavailable++
+		}
+		log.With("bangumi", video.Bangumi, "hash", video.M3U8Hash, "providers", count).Info("availability")
+		e = a.PushTo(seed.DatabaseCallback(video, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+			return model.UpdateVideoProviders(eng.Where(""), v.(*model.Video))
+		}))
+		if e != nil {
+			log.Error(e)
+		}
+	}
+	log.With("total", len(videos), "unavailable", unavailable).Info("availability done")
+	return nil
+}
+
+// FindProviders count the providers of hash through the routing api,the dht api is used on the older node,
+// the providers found are returned with the error if the query is not completed
+func FindProviders(ctx context.Context, api *httpapi.HttpApi, hash string, num int) (int, error) {
+	count, e := findProviders(ctx, api, "routing/findprovs", hash, num)
+	if e != nil && count == 0 {
+		return findProviders(ctx, api, "dht/findprovs", hash, num)
+	}
+	return count, e
+}
+
+func findProviders(ctx context.Context, api *httpapi.HttpApi, command string, hash string, num int) (int, error) {
+	resp, e := api.Request(command, hash).Option("num-providers", num).Send(ctx)
+	if e != nil {
+		return 0, e
+	}
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	defer resp.Close()
+
+	providers := make(map[string]bool)
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out struct {
+			Type      int
+			Responses []struct {
+				ID    string
+				Addrs []string
+			}
+		}
+		e := dec.Decode(&out)
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			//query time out or broken stream
+			return len(providers), e
+		}
+		if out.Type != providerQueryEvent {
+			continue
+		}
+		for _, r := range out.Responses {
+			providers[r.ID] = true
+		}
+	}
+	return len(providers), nil
+}
+
+var _ seed.APICaller = &availabilityCall{}
@@ -0,0 +1,67 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+)
+
+func stubProviderServer(routing bool) *httptest.Server {
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("arg") {
+		case "QmBroken":
+			_, _ = fmt.Fprintln(w, `{"Type":4,"Responses":[{"ID":"QmPeerA","Addrs":[]}]}`)
+			_, _ = fmt.Fprint(w, `{"Type":4,"Resp`)
+			return
+		case "QmTimeout":
+			_, _ = fmt.Fprint(w, `{"Type":1,`)
+			return
+		}
+		if r.URL.Query().Get("arg") != "QmAvailable" {
+			_, _ = fmt.Fprintln(w, `{"Type":3,"Responses":null}`)
+			return
+		}
+		_, _ = fmt.Fprintln(w, `{"Type":1,"Responses":null}`)
+		_, _ = fmt.Fprintln(w, `{"Type":4,"Responses":[{"ID":"QmPeerA","Addrs":[]}]}`)
+		_, _ = fmt.Fprintln(w, `{"Type":4,"Responses":[{"ID":"QmPeerB","Addrs":[]},{"ID":"QmPeerA","Addrs":[]}]}`)
+	}
+	if routing {
+		mux.HandleFunc("/api/v0/routing/findprovs", handler)
+	}
+	mux.HandleFunc("/api/v0/dht/findprovs", handler)
+	return httptest.NewServer(mux)
+}
+
+// TestFindProviders ...
+func TestFindProviders(t *testing.T) {
+	for _, routing := range []bool{true, false} {
+		srv := stubProviderServer(routing)
+		api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+		if e != nil {
+			t.Fatal(e)
+		}
+		count, e := FindProviders(context.Background(), api, "QmAvailable", DefaultNumProviders)
+		if e != nil || count != 2 {
+			t.Error(routing, count, e)
+		}
+		count, e = FindProviders(context.Background(), api, "QmUnavailable", DefaultNumProviders)
+		if e != nil || count != 0 {
+			t.Error(routing, count, e)
+		}
+		//the partial result is returned with the error
+		count, e = FindProviders(context.Background(), api, "QmBroken", DefaultNumProviders)
+		if e == nil || count != 1 {
+			t.Error(routing, count, e)
+		}
+		count, e = FindProviders(context.Background(), api, "QmTimeout", DefaultNumProviders)
+		if e == nil || count != 0 {
+			t.Error(routing, count, e)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,405 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+// CarManifestName ...
+const CarManifestName = "manifest.json"
+
+// CarManifestVersion ...
+const CarManifestVersion = 2
+
+// CarFile ...
+type CarFile struct {
+	Hash string     `json:"hash"`
+	Type model.Type `json:"type"`
+	File string     `json:"file"` //car file name in the export dir
+}
+
+// CarManifest the metadata written with the car files,
+// the sealed keys of the encrypted slices are opened by the same master key only
+type CarManifest struct {
+	Version    int                 `json:"version"`
+	Peer       string              `json:"peer"`
+	Created    time.Time           `json:"created"`
+	Files      []*CarFile          `json:"files"`
+	Videos     []CarRow            `json:"videos"`
+	Unfinished []*model.Unfinished `json:"unfinished"`
+	Keys       []CarRow            `json:"keys"`
+}
+
+// CarRow a row in the manifest keyed by the field names,the fields hidden from the json of the model are kept
+type CarRow map[string]json.RawMessage
+
+// NewCarRow the row of the exported fields of the model pointer v,the embedded model is skipped
+func NewCarRow(v interface{}) (CarRow, error) {
+	row := make(CarRow)
+	e := carFields(v, func(name string, field reflect.Value) error {
+		data, e := json.Marshal(field.Interface())
+		if e != nil {
+			return e
+		}
+		row[name] = data
+		return nil
+	})
+	return row, e
+}
+
+// Decode set the fields of the model pointer v from the row
+func (r CarRow) Decode(v interface{}) error {
+	return carFields(v, func(name string, field reflect.Value) error {
+		data, b := r[name]
+		if !b {
+			return nil
+		}
+		return json.Unmarshal(data, field.Addr().Interface())
+	})
+}
+
+func carFields(v interface{}, fn func(name string, field reflect.Value) error) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("car row of a non struct pointer")
+	}
+	val = val.Elem()
+	for i := 0; i < val.NumField(); i++ {
+		f := val.Type().Field(i)
+		if f.Anonymous || f.PkgPath != "" {
+			continue
+		}
+		e := fn(f.Name, val.Field(i))
+		if e != nil {
+			return fmt.Errorf("%s: %v", f.Name, e)
+		}
+	}
+	return nil
+}
+
+// CarExport export the dag of the videos to car files with a manifest
+type CarExport struct {
+	Path     string   //export dir
+	Bangumi  []string //export the bangumi only,empty to export all
+	SkipType []interface{}
+	Limit    int
+}
+
+// NewCarExport ...
+func NewCarExport(path string, bangumi ...string) *CarExport {
+	return &CarExport{
+		Path:    path,
+		Bangumi: bangumi,
+		Limit:   DefaultLimit,
+	}
+}
+
+// Task ...
+func (c *CarExport) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CarExport) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperDatabase, &carExportCall{
+			path:    c.Path,
+			bangumi: c.Bangumi,
+			skip:    c.SkipType,
+			limit:   c.Limit,
+		})
+	}
+}
+
+type carExportCall struct {
+	path    string
+	bangumi []string
+	skip    []interface{}
+	limit   int
+}
+
+// Call ...
+func (c *carExportCall) Call(database *seed.Database, eng *xorm.Engine) (e error) {
+	manifest, e := c.manifest(eng)
+	if e != nil {
+		return e
+	}
+	log.With("videos", len(manifest.Videos), "files", len(manifest.Files), "unfinished", len(manifest.Unfinished), "keys", len(manifest.Keys)).Info("car export")
+	return database.PushTo(seed.StepperAPI, &carExportAPI{
+		path:     c.path,
+		manifest: manifest,
+	})
+}
+
+// manifest the manifest of the videos and the unfinished rows of their hashes
+func (c *carExportCall) manifest(eng *xorm.Engine) (*CarManifest, error) {
+	session := eng.Where("")
+	if len(c.bangumi) > 0 {
+		session = session.In("bangumi", c.bangumi)
+	}
+	videos, e := model.AllVideos(session, c.limit)
+	if e != nil {
+		return nil, e
+	}
+	manifest := &CarManifest{
+		Version: CarManifestVersion,
+		Created: time.Now(),
+	}
+	set := make(PinDiffSet)
+	var hashes []string
+	for _, video := range *videos {
+		row, e := NewCarRow(video)
+		if e != nil {
+			return nil, e
+		}
+		manifest.Videos = append(manifest.Videos, row)
+		set.addVideo(video, c.skip)
+	}
+	for _, t := range pinDiffTypes {
+		for hash := range set[t] {
+			hashes = append(hashes, hash)
+			manifest.Files = append(manifest.Files, &CarFile{
+				Hash: hash,
+				Type: t,
+				File: hash + ".car",
+			})
+		}
+	}
+	if len(hashes) > 0 {
+		unfins := new([]*model.Unfinished)
+		e = eng.In("hash", hashes).Find(unfins)
+		if e != nil {
+			return nil, e
+		}
+		manifest.Unfinished = *unfins
+	}
+	//the rotated keys are exported too,the old slices are encrypted by them
+	var checksums []string
+	for _, unfin := range manifest.Unfinished {
+		if unfin.Encrypt {
+			checksums = append(checksums, unfin.Checksum)
+		}
+	}
+	if len(checksums) > 0 {
+		keys := new([]*model.VideoKey)
+		e = eng.In("checksum", checksums).Find(keys)
+		if e != nil {
+			return nil, e
+		}
+		for _, key := range *keys {
+			row, e := NewCarRow(key)
+			if e != nil {
+				return nil, e
+			}
+			manifest.Keys = append(manifest.Keys, row)
+		}
+	}
+	return manifest, nil
+}
+
+type carExportAPI struct {
+	path     string
+	manifest *CarManifest
+}
+
+// Call ...
+func (c *carExportAPI) Call(a *seed.API, api *httpapi.HttpApi) error {
+	e := os.MkdirAll(c.path, 0755)
+	if e != nil {
+		return e
+	}
+	if pid, e := seed.MyID(a); e == nil {
+		c.manifest.Peer = pid.ID
+	}
+	for i, file := range c.manifest.Files {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		e := DagExport(a.Context(), api, file.Hash, filepath.Join(c.path, file.File))
+		if e != nil {
+			return e
+		}
+		log.With("hash", file.Hash, "type", file.Type, "done", i+1, "total", len(c.manifest.Files)).Info("exported")
+	}
+	return seed.JSONWrite(filepath.Join(c.path, CarManifestName), c.manifest)
+}
+
+// DagExport write the dag of hash to the car file
+func DagExport(ctx context.Context, api *httpapi.HttpApi, hash string, path string) error {
+	resp, e := api.Request("dag/export", hash).Send(ctx)
+	if e != nil {
+		return e
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	defer resp.Close()
+	file, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+	_, e = io.Copy(file, resp.Output)
+	return e
+}
+
+// CarImport import the car files and the rows of a car export
+type CarImport struct {
+	Path string //export dir
+}
+
+// NewCarImport ...
+func NewCarImport(path string) *CarImport {
+	return &CarImport{
+		Path: path,
+	}
+}
+
+// Task ...
+func (c *CarImport) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CarImport) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		manifest := new(CarManifest)
+		e := seed.JSONRead(filepath.Join(c.Path, CarManifestName), manifest)
+		if e != nil {
+			return e
+		}
+		if manifest.Version != CarManifestVersion {
+			return errors.New("unsupported car manifest version")
+		}
+		return seeder.PushTo(seed.StepperAPI, &carImportAPI{
+			path:     c.Path,
+			manifest: manifest,
+		})
+	}
+}
+
+type carImportAPI struct {
+	path     string
+	manifest *CarManifest
+}
+
+// Call ...
+func (c *carImportAPI) Call(a *seed.API, api *httpapi.HttpApi) error {
+	for i, file := range c.manifest.Files {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		e := DagImport(a.Context(), api, filepath.Join(c.path, file.File))
+		if e != nil {
+			//the rows are not imported without the content
+			return e
+		}
+		log.With("hash", file.Hash, "type", file.Type, "done", i+1, "total", len(c.manifest.Files)).Info("imported")
+	}
+	return a.PushTo(seed.DatabaseCallback(c.manifest, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		return importCarManifest(eng, v.(*CarManifest))
+	}))
+}
+
+// importCarManifest add or update the rows of the manifest,the failed rows are returned as the error
+func importCarManifest(eng *xorm.Engine, manifest *CarManifest) error {
+	var errs []string
+	for _, unfin := range manifest.Unfinished {
+		unfin.ID = ""
+		e := model.AddOrUpdateUnfinished(eng.Where(""), unfin)
+		if e != nil {
+			errs = append(errs, fmt.Sprintf("unfinished %s(%s): %v", unfin.Checksum, unfin.Type, e))
+		}
+	}
+	for _, row := range manifest.Keys {
+		key := new(model.VideoKey)
+		e := row.Decode(key)
+		if e == nil {
+			key.Model = model.Model{}
+			e = model.ImportVideoKey(eng.Where(""), key)
+		}
+		if e != nil {
+			errs = append(errs, fmt.Sprintf("key %s: %v", key.KeyID, e))
+		}
+	}
+	for _, row := range manifest.Videos {
+		video := new(model.Video)
+		e := row.Decode(video)
+		if e == nil {
+			e = model.AddOrUpdateVideo(eng.Where(""), video)
+		}
+		if e != nil {
+			errs = append(errs, fmt.Sprintf("video %s: %v", video.Bangumi, e))
+		}
+	}
+	log.With("peer", manifest.Peer, "videos", len(manifest.Videos), "unfinished", len(manifest.Unfinished), "keys", len(manifest.Keys), "failed", len(errs)).Info("car import")
+	if len(errs) > 0 {
+		return fmt.Errorf("car import failed on %d rows: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DagImport import the car file and pin the roots
+func DagImport(ctx context.Context, api *httpapi.HttpApi, path string) error {
+	file, e := os.Open(path)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+	resp, e := api.Request("dag/import").Option("pin-roots", true).FileBody(file).Send(ctx)
+	if e != nil {
+		return e
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	defer resp.Close()
+	dec := json.NewDecoder(resp.Output)
+	for {
+		var out struct {
+			Root *struct {
+				Cid struct {
+					Root string `json:"/"`
+				}
+				PinErrorMsg string
+			}
+		}
+		e := dec.Decode(&out)
+		if e == io.EOF {
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		if out.Root != nil && out.Root.PinErrorMsg != "" {
+			return errors.New(out.Root.Cid.Root + ": " + out.Root.PinErrorMsg)
+		}
+	}
+}
+
+var _ seed.DatabaseCaller = &carExportCall{}
+var _ seed.APICaller = &carExportAPI{}
+var _ seed.APICaller = &carImportAPI{}
@@ -0,0 +1,170 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/xormsharp/xorm"
+)
+
+// TestDagExportImport ...
+func TestDagExportImport(t *testing.T) {
+	car := "car content"
+	var imported string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/dag/export", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, car)
+	})
+	mux.HandleFunc("/api/v0/dag/import", func(w http.ResponseWriter, r *http.Request) {
+		reader, e := r.MultipartReader()
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		part, e := reader.NextPart()
+		if e != nil {
+			t.Error(e)
+			return
+		}
+		bytes, _ := ioutil.ReadAll(part)
+		imported = string(bytes)
+		_, _ = fmt.Fprintln(w, `{"Root":{"Cid":{"/":"QmRoot"},"PinErrorMsg":""}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	dir, e := ioutil.TempDir("", "car")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "QmRoot.car")
+	e = DagExport(context.Background(), api, "QmRoot", path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = DagImport(context.Background(), api, path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if imported != car {
+		t.Error(imported)
+	}
+}
+
+func carTestEngine(t *testing.T, dir string, name string) *xorm.Engine {
+	eng, e := model.InitSQLite3(filepath.Join(dir, name))
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = eng.Sync2(model.Video{}, model.Unfinished{}, model.VideoKey{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	return eng
+}
+
+// TestCarManifest ...
+func TestCarManifest(t *testing.T) {
+	dir, e := ioutil.TempDir("", "car")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	from := carTestEngine(t, dir, "from.db")
+	defer from.Close()
+	to := carTestEngine(t, dir, "to.db")
+	defer to.Close()
+
+	for _, episode := range []string{"1", "2"} {
+		e = model.AddOrUpdateVideo(from.Where(""), &model.Video{
+			Bangumi:       "ABP-123",
+			Season:        "1",
+			Episode:       episode,
+			M3U8Hash:      "QmSlice" + episode,
+			M3U8:          "media.m3u8",
+			Director:      "director",
+			Caption:       "zh",
+			AudioLanguage: []string{"jpn", "eng"},
+			Language:      "日语",
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	e = model.AddOrUpdateUnfinished(from.Where(""), &model.Unfinished{Checksum: "sum", Type: model.TypeSlice, Hash: "QmSlice1", Encrypt: true, Key: "key1"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, id := range []string{"key0", "key1"} {
+		e = model.AddVideoKey(from.Where(""), &model.VideoKey{KeyID: id, Checksum: "sum", Key: "sealed-" + id})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	manifest, e := (&carExportCall{}).manifest(from)
+	if e != nil {
+		t.Fatal(e)
+	}
+	data, e := json.Marshal(manifest)
+	if e != nil {
+		t.Fatal(e)
+	}
+	imported := new(CarManifest)
+	e = json.Unmarshal(data, imported)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = importCarManifest(to, imported)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	videos, e := model.AllVideos(to.Where("bangumi = ?", "ABP-123"), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(*videos) != 2 {
+		t.Fatalf("%d videos imported", len(*videos))
+	}
+	for _, video := range *videos {
+		if video.M3U8Hash != "QmSlice"+video.Episode || video.Season != "1" || video.M3U8 != "media.m3u8" ||
+			video.Director != "director" || video.Caption != "zh" || strings.Join(video.AudioLanguage, ",") != "jpn,eng" || video.Language != "日语" {
+			t.Errorf("%+v", video)
+		}
+	}
+	u := new(model.Unfinished)
+	if b, e := to.Where("hash = ?", "QmSlice1").Get(u); e != nil || !b || u.Checksum != "sum" {
+		t.Error(b, e, u)
+	}
+	//the sealed keys are imported with the rotation
+	keys, e := model.AllVideoKey(to.Where(""), "sum")
+	if e != nil || len(*keys) != 2 {
+		t.Fatal(keys, e)
+	}
+	if key := (*keys)[0]; key.KeyID != "key1" || key.Key != "sealed-key1" || !key.Current || key.Rotate != 1 {
+		t.Errorf("%+v", key)
+	}
+
+	//the failed rows are returned
+	e = importCarManifest(to, &CarManifest{Videos: []CarRow{{"Bangumi": json.RawMessage(`"SSNI-001"`), "Episode": json.RawMessage(`1`)}}})
+	if e == nil || !strings.Contains(e.Error(), "Episode") {
+		t.Error(e)
+	}
+}
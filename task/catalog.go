@@ -0,0 +1,418 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+// CatalogVersion ...
+const CatalogVersion = 1
+
+// CatalogLink ipld link
+type CatalogLink struct {
+	Root string `json:"/"`
+}
+
+// CatalogRoot the root node of the catalog,links the shards by bangumi prefix
+type CatalogRoot struct {
+	Version int                    `json:"version"`
+	Created int64                  `json:"created"`
+	Count   int                    `json:"count"`
+	Shards  map[string]CatalogLink `json:"shards"`
+}
+
+// CatalogShard ...
+type CatalogShard struct {
+	Prefix string          `json:"prefix"`
+	Videos []*CatalogEntry `json:"videos"`
+}
+
+// CatalogEntry the video info in catalog,the hashes are not linked so pin the catalog do not fetch the contents
+type CatalogEntry struct {
+	Bangumi      string             `json:"bangumi"`
+	Intro        string             `json:"intro"`
+	Alias        []string           `json:"alias"`
+	Role         []string           `json:"role"`
+	Director     string             `json:"director"`
+	Systematics  string             `json:"systematics"`
+	Season       string             `json:"season"`
+	TotalEpisode string             `json:"total_episode"`
+	Episode      string             `json:"episode"`
+	Producer     string             `json:"producer"`
+	Publisher    string             `json:"publisher"`
+	Type         string             `json:"type"`
+	Format       string             `json:"format"`
+	Language     string             `json:"language"`
+	Caption      string             `json:"caption"`
+	Date         string             `json:"date"`
+	Sharpness    string             `json:"sharpness"`
+	Series       string             `json:"series"`
+	Tags         []string           `json:"tags"`
+	Length       string             `json:"length"`
+	Uncensored   bool               `json:"uncensored"`
+	ThumbHash    string             `json:"thumb_hash"`
+	PreviewHash  string             `json:"preview_hash,omitempty"`
+	PosterHash   string             `json:"poster_hash"`
+	SourceHash   string             `json:"source_hash"`
+	M3U8Hash     string             `json:"m3u8_hash"`
+	M3U8         string             `json:"m3u8"`
+	MPD          string             `json:"mpd,omitempty"`
+	SliceFormat  string             `json:"slice_format,omitempty"`
+	Trickplay    string             `json:"trickplay,omitempty"`
+	Renditions   []*model.Rendition `json:"renditions,omitempty"`
+	Audio        []string           `json:"audio,omitempty"` //the languages of the audio tracks
+}
+
+// NewCatalogEntry ...
+func NewCatalogEntry(video *model.Video) *CatalogEntry {
+	return &CatalogEntry{
+		Bangumi:      video.Bangumi,
+		Intro:        video.Intro,
+		Alias:        video.Alias,
+		Role:         video.Role,
+		Director:     video.Director,
+		Systematics:  video.Systematics,
+		Season:       video.Season,
+		TotalEpisode: video.TotalEpisode,
+		Episode:      video.Episode,
+		Producer:     video.Producer,
+		Publisher:    video.Publisher,
+		Type:         video.Type,
+		Format:       video.Format,
+		Language:     video.Language,
+		Caption:      video.Caption,
+		Date:         video.Date,
+		Sharpness:    video.Sharpness,
+		Series:       video.Series,
+		Tags:         video.Tags,
+		Length:       video.Length,
+		Uncensored:   video.Uncensored,
+		ThumbHash:    video.ThumbHash,
+		PreviewHash:  video.PreviewHash,
+		PosterHash:   video.PosterHash,
+		SourceHash:   video.SourceHash,
+		M3U8Hash:     video.M3U8Hash,
+		M3U8:         video.M3U8,
+		MPD:          video.MPD,
+		SliceFormat:  video.SliceFormat,
+		Trickplay:    video.Trickplay,
+		Renditions:   video.Renditions,
+		Audio:        video.AudioLanguage,
+	}
+}
+
+// Video ...
+func (c *CatalogEntry) Video() *model.Video {
+	return &model.Video{
+		Bangumi:       c.Bangumi,
+		Intro:         c.Intro,
+		Alias:         c.Alias,
+		Role:          c.Role,
+		Director:      c.Director,
+		Systematics:   c.Systematics,
+		Season:        c.Season,
+		TotalEpisode:  c.TotalEpisode,
+		Episode:       c.Episode,
+		Producer:      c.Producer,
+		Publisher:     c.Publisher,
+		Type:          c.Type,
+		Format:        c.Format,
+		Language:      c.Language,
+		Caption:       c.Caption,
+		Date:          c.Date,
+		Sharpness:     c.Sharpness,
+		Series:        c.Series,
+		Tags:          c.Tags,
+		Length:        c.Length,
+		Uncensored:    c.Uncensored,
+		ThumbHash:     c.ThumbHash,
+		PreviewHash:   c.PreviewHash,
+		PosterHash:    c.PosterHash,
+		SourceHash:    c.SourceHash,
+		M3U8Hash:      c.M3U8Hash,
+		M3U8:          c.M3U8,
+		MPD:           c.MPD,
+		SliceFormat:   c.SliceFormat,
+		Trickplay:     c.Trickplay,
+		Renditions:    c.Renditions,
+		AudioLanguage: c.Audio,
+	}
+}
+
+// CatalogPrefix the shard prefix of bangumi,ABP-123 => ABP
+func CatalogPrefix(bangumi string) string {
+	prefix := strings.ToUpper(strings.TrimSpace(strings.SplitN(bangumi, "-", 2)[0]))
+	if prefix == "" {
+		return "_"
+	}
+	return prefix
+}
+
+// CatalogPublish publish the video catalog as dag-json
+type CatalogPublish struct {
+	Output string //write the root cid to the json file
+	Key    string //publish the root under the ipns key with the history,empty to skip
+}
+
+// NewCatalogPublish ...
+func NewCatalogPublish() *CatalogPublish {
+	return &CatalogPublish{}
+}
+
+// Task ...
+func (c *CatalogPublish) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CatalogPublish) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperAPI, &catalogPublish{
+			output: c.Output,
+			key:    c.Key,
+		})
+	}
+}
+
+type catalogPublish struct {
+	output string
+	key    string
+}
+
+// Call ...
+func (c *catalogPublish) Call(a *seed.API, api *httpapi.HttpApi) error {
+	v := make(chan *model.Video)
+	e := a.PushTo(seed.DatabaseVideoCall(v, func(session *xorm.Session) *xorm.Session {
+		return session
+	}))
+	if e != nil {
+		return e
+	}
+	var videos []*model.Video
+	for video := range v {
+		if video == nil {
+			break
+		}
+		videos = append(videos, video)
+	}
+	root, e := PublishCatalog(a.Context(), api, videos)
+	if e != nil {
+		return e
+	}
+	log.With("root", root, "videos", len(videos)).Info("catalog published")
+	if c.key != "" {
+		e = c.publishName(a, api, root, len(videos))
+		if e != nil {
+			return e
+		}
+	}
+	if c.output != "" {
+		return seed.JSONWrite(c.output, CatalogLink{Root: root})
+	}
+	return nil
+}
+
+// publishName publish the root under the key linked to the record published last time,
+// the published record is kept in the database for the next one
+func (c *catalogPublish) publishName(a *seed.API, api *httpapi.HttpApi, root string, count int) error {
+	catalog, e := findCatalog(a, func(eng *xorm.Engine) (*model.Catalog, error) {
+		return model.FindPublishedCatalog(eng.Where(""), c.key)
+	})
+	if e != nil {
+		return e
+	}
+	if catalog == nil {
+		catalog = &model.Catalog{PublishKey: c.key}
+	}
+	name, record, e := PublishCatalogName(a.Context(), api, c.key, catalog.Record, root, count)
+	if e != nil {
+		return e
+	}
+	log.With("name", name, "record", record, "prev", catalog.Record).Info("catalog name published")
+	catalog.Name = name
+	catalog.Record = record
+	catalog.Catalog = root
+	catalog.Count = count
+	return a.PushTo(seed.DatabaseCallback(catalog, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		return model.AddOrUpdateCatalog(eng.Where(""), v.(*model.Catalog))
+	}))
+}
+
+// findCatalog find the catalog through the database thread,the wait ends with the context
+func findCatalog(a *seed.API, find func(eng *xorm.Engine) (*model.Catalog, error)) (*model.Catalog, error) {
+	type result struct {
+		catalog *model.Catalog
+		e       error
+	}
+	r := make(chan result, 1)
+	e := a.PushTo(seed.DatabaseCallback(nil, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		catalog, e := find(eng)
+		r <- result{catalog: catalog, e: e}
+		return e
+	}))
+	if e != nil {
+		return nil, e
+	}
+	select {
+	case <-a.Context().Done():
+		return nil, a.Context().Err()
+	case v := <-r:
+		return v.catalog, v.e
+	}
+}
+
+// PublishCatalog put the shards and the root of videos,returns the root cid
+func PublishCatalog(ctx context.Context, api *httpapi.HttpApi, videos []*model.Video) (string, error) {
+	shards := make(map[string]*CatalogShard)
+	for _, video := range videos {
+		prefix := CatalogPrefix(video.Bangumi)
+		shard, b := shards[prefix]
+		if !b {
+			shard = &CatalogShard{Prefix: prefix}
+			shards[prefix] = shard
+		}
+		shard.Videos = append(shard.Videos, NewCatalogEntry(video))
+	}
+	root := &CatalogRoot{
+		Version: CatalogVersion,
+		Created: time.Now().Unix(),
+		Count:   len(videos),
+		Shards:  make(map[string]CatalogLink, len(shards)),
+	}
+	for prefix, shard := range shards {
+		//keep the same cid for the same catalog
+		sort.Slice(shard.Videos, func(i, j int) bool {
+			if shard.Videos[i].Bangumi != shard.Videos[j].Bangumi {
+				return shard.Videos[i].Bangumi < shard.Videos[j].Bangumi
+			}
+			if shard.Videos[i].Season != shard.Videos[j].Season {
+				return shard.Videos[i].Season < shard.Videos[j].Season
+			}
+			return shard.Videos[i].Episode < shard.Videos[j].Episode
+		})
+		cid, e := DagPut(ctx, api, shard)
+		if e != nil {
+			return "", e
+		}
+		root.Shards[prefix] = CatalogLink{Root: cid}
+	}
+	return DagPut(ctx, api, root)
+}
+
+// DagPut put v as a dag-json node,returns the cid
+func DagPut(ctx context.Context, api *httpapi.HttpApi, v interface{}) (string, error) {
+	data, e := json.Marshal(v)
+	if e != nil {
+		return "", e
+	}
+	var out struct {
+		Cid CatalogLink
+	}
+	e = api.Request("dag/put").
+		Option("store-codec", "dag-json").
+		Option("input-codec", "dag-json").
+		Option("pin", true).
+		FileBody(bytes.NewReader(data)).Exec(ctx, &out)
+	if e != nil {
+		return "", e
+	}
+	return out.Cid.Root, nil
+}
+
+// DagGet get the dag-json node of cid into v
+func DagGet(ctx context.Context, api *httpapi.HttpApi, cid string, v interface{}) error {
+	return api.Request("dag/get", cid).Option("output-codec", "dag-json").Exec(ctx, v)
+}
+
+// CatalogImport import the videos of a catalog root
+type CatalogImport struct {
+	Root string
+}
+
+// NewCatalogImport ...
+func NewCatalogImport(root string) *CatalogImport {
+	return &CatalogImport{
+		Root: root,
+	}
+}
+
+// Task ...
+func (c *CatalogImport) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CatalogImport) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperAPI, &catalogImport{
+			root: c.Root,
+		})
+	}
+}
+
+type catalogImport struct {
+	root string
+}
+
+// Call ...
+func (c *catalogImport) Call(a *seed.API, api *httpapi.HttpApi) error {
+	videos, e := ImportCatalog(a.Context(), api, c.root)
+	if e != nil {
+		return e
+	}
+	return a.PushTo(seed.DatabaseCallback(videos, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		videos := v.([]*model.Video)
+		for _, video := range videos {
+			e = model.AddOrUpdateVideo(eng.Where(""), video)
+			if e != nil {
+				log.With("bangumi", video.Bangumi).Error(e)
+			}
+		}
+		log.With("root", c.root, "videos", len(videos)).Info("catalog imported")
+		return nil
+	}))
+}
+
+// ImportCatalog walk the catalog root and returns the videos
+func ImportCatalog(ctx context.Context, api *httpapi.HttpApi, cid string) ([]*model.Video, error) {
+	root := new(CatalogRoot)
+	e := DagGet(ctx, api, cid, root)
+	if e != nil {
+		return nil, e
+	}
+	if root.Version != CatalogVersion {
+		return nil, errors.New("unsupported catalog version")
+	}
+	var videos []*model.Video
+	for prefix, link := range root.Shards {
+		shard := new(CatalogShard)
+		e := DagGet(ctx, api, link.Root, shard)
+		if e != nil {
+			return nil, e
+		}
+		log.With("prefix", prefix, "videos", len(shard.Videos)).Info("catalog shard")
+		for _, entry := range shard.Videos {
+			videos = append(videos, entry.Video())
+		}
+	}
+	return videos, nil
+}
+
+var _ seed.APICaller = &catalogPublish{}
+var _ seed.APICaller = &catalogImport{}
@@ -0,0 +1,233 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/xormsharp/xorm"
+)
+
+// DefaultCatalogInterval ...
+const DefaultCatalogInterval = 10 * time.Minute
+
+// CatalogRecord the history record published under the ipns name,each record links its predecessor
+type CatalogRecord struct {
+	Catalog   CatalogLink  `json:"catalog"`
+	Prev      *CatalogLink `json:"prev,omitempty"`
+	Published int64        `json:"published"`
+	Count     int          `json:"count"`
+}
+
+// PublishCatalogName put a record of the catalog root linked to the prev record and publish it under the key,
+// the prev is the record published last time,empty for the first one.
+// the key is generated if not exist,returns the ipns name and the record cid
+func PublishCatalogName(ctx context.Context, api *httpapi.HttpApi, key string, prev string, root string, count int) (string, string, error) {
+	k, e := catalogKey(ctx, api, key)
+	if e != nil {
+		return "", "", e
+	}
+	record := &CatalogRecord{
+		Catalog:   CatalogLink{Root: root},
+		Published: time.Now().Unix(),
+		Count:     count,
+	}
+	if prev != "" {
+		record.Prev = &CatalogLink{Root: prev}
+	}
+	cid, e := DagPut(ctx, api, record)
+	if e != nil {
+		return "", "", e
+	}
+	_, e = api.Name().Publish(ctx, path.New("/ipfs/"+cid), options.Name.Key(key), options.Name.AllowOffline(true))
+	if e != nil {
+		return "", "", e
+	}
+	return k.Path().String(), cid, nil
+}
+
+func catalogKey(ctx context.Context, api *httpapi.HttpApi, name string) (iface.Key, error) {
+	keys, e := api.Key().List(ctx)
+	if e != nil {
+		return nil, e
+	}
+	for _, k := range keys {
+		if k.Name() == name {
+			return k, nil
+		}
+	}
+	log.With("key", name).Info("generate catalog key")
+	return api.Key().Generate(ctx, name)
+}
+
+// ResolveCatalogName resolve the ipns name to the record cid
+func ResolveCatalogName(ctx context.Context, api *httpapi.HttpApi, name string) (string, error) {
+	if !strings.HasPrefix(name, "/ipns/") {
+		name = "/ipns/" + name
+	}
+	p, e := api.Name().Resolve(ctx, name)
+	if e != nil {
+		return "", e
+	}
+	return strings.TrimPrefix(p.String(), "/ipfs/"), nil
+}
+
+// CatalogChanges returns the videos added or changed in the new catalog,the unchanged shards are skipped
+func CatalogChanges(ctx context.Context, api *httpapi.HttpApi, from, to *CatalogRoot) ([]*model.Video, error) {
+	var videos []*model.Video
+	for prefix, link := range to.Shards {
+		oldLink, b := from.Shards[prefix]
+		if b && oldLink.Root == link.Root {
+			continue
+		}
+		exist := make(map[string]string)
+		if b {
+			shard := new(CatalogShard)
+			e := DagGet(ctx, api, oldLink.Root, shard)
+			if e != nil {
+				return nil, e
+			}
+			for _, entry := range shard.Videos {
+				data, _ := json.Marshal(entry)
+				exist[entry.key()] = string(data)
+			}
+		}
+		shard := new(CatalogShard)
+		e := DagGet(ctx, api, link.Root, shard)
+		if e != nil {
+			return nil, e
+		}
+		for _, entry := range shard.Videos {
+			data, _ := json.Marshal(entry)
+			if exist[entry.key()] == string(data) {
+				continue
+			}
+			videos = append(videos, entry.Video())
+		}
+	}
+	return videos, nil
+}
+
+func (c *CatalogEntry) key() string {
+	return strings.Join([]string{c.Bangumi, c.Season, c.Episode}, "/")
+}
+
+// CatalogSubscribe resolve the ipns name periodically and apply the catalog changes
+type CatalogSubscribe struct {
+	Name     string
+	Interval time.Duration
+}
+
+// NewCatalogSubscribe ...
+func NewCatalogSubscribe(name string) *CatalogSubscribe {
+	return &CatalogSubscribe{
+		Name:     name,
+		Interval: DefaultCatalogInterval,
+	}
+}
+
+// Task ...
+func (c *CatalogSubscribe) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CatalogSubscribe) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		e := seeder.PushTo(seed.StepperAPI, &catalogSubscribe{name: c.Name})
+		if e != nil || c.Interval <= 0 {
+			return e
+		}
+		go func() {
+			ticker := time.NewTicker(c.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-seeder.Context().Done():
+					return
+				case <-ticker.C:
+					e := seeder.PushTo(seed.StepperAPI, &catalogSubscribe{name: c.Name})
+					if e != nil {
+						log.With("name", c.Name).Error(e)
+					}
+				}
+			}
+		}()
+		return nil
+	}
+}
+
+type catalogSubscribe struct {
+	name string
+}
+
+// Call ...
+func (c *catalogSubscribe) Call(a *seed.API, api *httpapi.HttpApi) error {
+	record, e := ResolveCatalogName(a.Context(), api, c.name)
+	if e != nil {
+		return e
+	}
+	catalog, e := findCatalog(a, func(eng *xorm.Engine) (*model.Catalog, error) {
+		return model.FindCatalog(eng.Where(""), c.name)
+	})
+	if e != nil {
+		return e
+	}
+	if catalog == nil {
+		catalog = &model.Catalog{Name: c.name}
+	}
+	if catalog.Record == record {
+		log.With("name", c.name, "record", record).Info("catalog up to date")
+		return nil
+	}
+
+	current := new(CatalogRecord)
+	e = DagGet(a.Context(), api, record, current)
+	if e != nil {
+		return e
+	}
+
+	//the changes between the applied catalog and the current one cover the records published in between
+	old := &CatalogRoot{}
+	if catalog.Catalog != "" {
+		e = DagGet(a.Context(), api, catalog.Catalog, old)
+		if e != nil {
+			return e
+		}
+	}
+	root := new(CatalogRoot)
+	e = DagGet(a.Context(), api, current.Catalog.Root, root)
+	if e != nil {
+		return e
+	}
+	videos, e := CatalogChanges(a.Context(), api, old, root)
+	if e != nil {
+		return e
+	}
+	log.With("name", c.name, "record", record, "changes", len(videos)).Info("catalog subscribe")
+
+	catalog.Record = record
+	catalog.Catalog = current.Catalog.Root
+	catalog.Count = root.Count
+	return a.PushTo(seed.DatabaseCallback(videos, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		for _, video := range v.([]*model.Video) {
+			e = model.AddOrUpdateVideo(eng.Where(""), video)
+			if e != nil {
+				log.With("bangumi", video.Bangumi).Error(e)
+			}
+		}
+		return model.AddOrUpdateCatalog(eng.Where(""), catalog)
+	}))
+}
+
+var _ seed.APICaller = &catalogSubscribe{}
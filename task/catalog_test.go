@@ -0,0 +1,191 @@
+package task
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+func stubDagServer(t *testing.T) *httptest.Server {
+	nodes := make(map[string][]byte)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/dag/put", func(w http.ResponseWriter, r *http.Request) {
+		reader, e := r.MultipartReader()
+		if e != nil {
+			t.Fatal(e)
+		}
+		part, e := reader.NextPart()
+		if e != nil {
+			t.Fatal(e)
+		}
+		data, _ := ioutil.ReadAll(part)
+		sum := sha1.Sum(data)
+		cid := "Qm" + hex.EncodeToString(sum[:])
+		nodes[cid] = data
+		_, _ = fmt.Fprintf(w, `{"Cid":{"/":"%s"}}`, cid)
+	})
+	mux.HandleFunc("/api/v0/dag/get", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(nodes[r.URL.Query().Get("arg")])
+	})
+	return httptest.NewServer(mux)
+}
+
+// TestCatalog ...
+func TestCatalog(t *testing.T) {
+	srv := stubDagServer(t)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+	videos := []*model.Video{
+		{Bangumi: "ABP-123", M3U8Hash: "QmSlice1", Season: "1", Episode: "1"},
+		{Bangumi: "abp-456", M3U8Hash: "QmSlice2"},
+		{Bangumi: "SSNI-001", SourceHash: "QmSource"},
+	}
+	root, e := PublishCatalog(context.Background(), api, videos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	imported, e := ImportCatalog(context.Background(), api, root)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(imported) != len(videos) {
+		t.Fatal(imported)
+	}
+	found := make(map[string]*model.Video)
+	for _, video := range imported {
+		found[video.Bangumi] = video
+	}
+	if v := found["ABP-123"]; v == nil || v.M3U8Hash != "QmSlice1" || v.Episode != "1" {
+		t.Error(v)
+	}
+	if v := found["SSNI-001"]; v == nil || v.SourceHash != "QmSource" {
+		t.Error(v)
+	}
+	if CatalogPrefix("abp-456") != "ABP" || CatalogPrefix("") != "_" {
+		t.Error("prefix")
+	}
+}
+
+// TestCatalogChanges ...
+func TestCatalogChanges(t *testing.T) {
+	srv := stubDagServer(t)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+	videos := []*model.Video{
+		{Bangumi: "ABP-123", M3U8Hash: "QmSlice1"},
+		{Bangumi: "SSNI-001", SourceHash: "QmSource"},
+	}
+	from, e := PublishCatalog(context.Background(), api, videos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	videos[0].M3U8Hash = "QmSlice2"
+	videos = append(videos, &model.Video{Bangumi: "ABP-456"})
+	to, e := PublishCatalog(context.Background(), api, videos)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	fromRoot, toRoot := new(CatalogRoot), new(CatalogRoot)
+	if e := DagGet(context.Background(), api, from, fromRoot); e != nil {
+		t.Fatal(e)
+	}
+	if e := DagGet(context.Background(), api, to, toRoot); e != nil {
+		t.Fatal(e)
+	}
+	changes, e := CatalogChanges(context.Background(), api, fromRoot, toRoot)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(changes) != 2 {
+		t.Fatal(changes)
+	}
+	for _, video := range changes {
+		if video.Bangumi == "SSNI-001" || (video.Bangumi == "ABP-123" && video.M3U8Hash != "QmSlice2") {
+			t.Error(video)
+		}
+	}
+}
+
+// TestFindCatalog ...
+func TestFindCatalog(t *testing.T) {
+	dir, e := ioutil.TempDir("", "catalog")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	eng, e := model.InitSQLite3(filepath.Join(dir, "test.db"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer eng.Close()
+	e = eng.Sync2(model.Catalog{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = model.AddOrUpdateCatalog(eng.Where(""), &model.Catalog{Name: "/ipns/QmName", Record: "QmRecord", PublishKey: "catalog"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	find := func(eng *xorm.Engine) (*model.Catalog, error) {
+		return model.FindPublishedCatalog(eng.Where(""), "catalog")
+	}
+
+	db := seed.NewDatabase(eng)
+	api := seed.NewAPI("/ip4/127.0.0.1/tcp/5001")
+	s := seed.NewSeed(db, api)
+	api.BeforeRun(s)
+	db.BeforeRun(s)
+	go db.Run(s.Context())
+	catalog, e := findCatalog(api, find)
+	if e != nil || catalog == nil || catalog.Record != "QmRecord" {
+		t.Error(catalog, e)
+	}
+	s.Stop()
+
+	//the database thread is not running,the wait ends with the context
+	db = seed.NewDatabase(eng)
+	api = seed.NewAPI("/ip4/127.0.0.1/tcp/5001")
+	s = seed.NewSeed(db, api)
+	api.BeforeRun(s)
+	db.BeforeRun(s)
+	done := make(chan error, 1)
+	go func() {
+		_, e := findCatalog(api, find)
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		t.Fatal("found without the database thread", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+	s.Stop()
+	select {
+	case e := <-done:
+		if e == nil {
+			t.Error("found after the context is done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the wait does not end with the context")
+	}
+}
@@ -0,0 +1,307 @@
+package task
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/xormsharp/xorm"
+)
+
+// cidSniffLen bytes read to detect the file type
+const cidSniffLen = 512
+
+// cidProbeLen bytes fetched to probe the video
+const cidProbeLen = 8 << 20
+
+var resolutionRegexp = regexp.MustCompile(`RESOLUTION=\d+x(\d+)`)
+
+var heightRegexp = regexp.MustCompile(`<Representation[^>]*\sheight="(\d+)"`)
+
+// CIDImportItem ...
+type CIDImportItem struct {
+	Hash    string `json:"hash"`
+	Bangumi string `json:"bangumi"` //bangumi hint,set as the relate of unfinished
+}
+
+// CIDImport import the content already on ipfs by cid
+type CIDImport struct {
+	Items []*CIDImportItem
+}
+
+// NewCIDImport load the items from the json file
+func NewCIDImport(path string) (*CIDImport, error) {
+	var items []*CIDImportItem
+	e := seed.JSONRead(path, &items)
+	if e != nil {
+		return nil, e
+	}
+	return &CIDImport{
+		Items: items,
+	}, nil
+}
+
+// Task ...
+func (c *CIDImport) Task() *seed.Task {
+	return seed.NewTask(c)
+}
+
+// CallTask ...
+func (c *CIDImport) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperAPI, &cidImport{
+			items: c.Items,
+		})
+	}
+}
+
+type cidImport struct {
+	items []*CIDImportItem
+}
+
+// Call ...
+func (c *cidImport) Call(a *seed.API, api *httpapi.HttpApi) error {
+	failed := 0
+	for i, item := range c.items {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		u, e := InspectCID(a.Context(), api, item.Hash)
+		if e != nil {
+			failed++
+			log.With("hash", item.Hash).Error(e)
+			continue
+		}
+		u.Relate = item.Bangumi
+		if item.Bangumi != "" {
+			u.Name = item.Bangumi
+		}
+		log.With("hash", u.Hash, "type", u.Type, "sharpness", u.Sharpness, "done", i+1, "total", len(c.items)).Info("cid import")
+		if u.Type == model.TypeOther {
+			continue
+		}
+		e = a.PushTo(seed.DatabaseCallback(u, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+			return model.AddOrUpdateUnfinished(eng.Where(""), v.(*model.Unfinished))
+		}))
+		if e != nil {
+			log.Error(e)
+		}
+	}
+	log.With("total", len(c.items), "failed", failed).Info("cid import done")
+	return nil
+}
+
+// InspectCID inspect the dag of hash and returns the unfinished of it,
+// the checksum of the unfinished is the hash as the source file is unknown
+func InspectCID(ctx context.Context, api *httpapi.HttpApi, hash string) (*model.Unfinished, error) {
+	u := &model.Unfinished{
+		Checksum: hash,
+		Type:     model.TypeOther,
+		Name:     hash,
+		Hash:     hash,
+		Object:   new(model.VideoObject),
+	}
+	//the blocks of a chunked file are listed without name,a file without blocks may fail to list
+	obj, e := seed.LsObject(ctx, api, path.New("/ipfs/"+hash))
+	if e == nil && isDirObject(obj) {
+		u.Object = obj
+		if !sliceLayout(u, obj) {
+			log.With("hash", hash).Error("unsupported directory layout")
+			return u, nil
+		}
+		if u.M3U8 != "" {
+			u.Sharpness, e = probeSlice(ctx, api, hash, u.M3U8)
+		} else {
+			u.Sharpness, e = probeMPD(ctx, api, hash, u.MPD)
+		}
+		if e != nil {
+			log.With("hash", hash).Error(e)
+		}
+		return u, nil
+	}
+
+	head, e := catCID(ctx, api, hash, cidSniffLen)
+	if e != nil {
+		return nil, e
+	}
+	content := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(content, "image/"):
+		u.Type = model.TypePoster
+	case strings.HasPrefix(content, "video/") || isVideoHead(head):
+		u.Type = model.TypeVideo
+		u.Sharpness, e = probeCID(ctx, api, hash)
+		if e != nil {
+			log.With("hash", hash).Error(e)
+		}
+	}
+	return u, nil
+}
+
+// sliceLayout detect the manifests and the segments of the slice directory,
+// returns false if neither the m3u8 nor the mpd is found
+func sliceLayout(u *model.Unfinished, obj *model.VideoObject) bool {
+	segment := ""
+	for _, link := range obj.Links {
+		switch ext := filepath.Ext(link.Name); {
+		case ext == ".m3u8" && u.M3U8 == "":
+			u.M3U8 = link.Name
+		case ext == ".mpd" && u.MPD == "":
+			u.MPD = link.Name
+		case (ext == ".ts" || ext == ".m4s") && segment == "" && !strings.HasPrefix(link.Name, "init"):
+			segment = link.Name
+		}
+	}
+	if u.M3U8 == "" && u.MPD == "" {
+		return false
+	}
+	u.Type = model.TypeSlice
+	switch {
+	case u.MPD != "" && u.M3U8 != "":
+		u.SliceFormat = string(seed.SliceFormatCMAF)
+		u.SegmentFile = seed.SliceFormatCMAF.SegmentFile()
+	case u.MPD != "":
+		u.SliceFormat = string(seed.SliceFormatDASH)
+		u.SegmentFile = seed.SliceFormatDASH.SegmentFile()
+	case filepath.Ext(segment) == ".m4s":
+		u.SliceFormat = string(seed.SliceFormatFMP4)
+		u.SegmentFile = segmentPattern(segment)
+	case segment != "":
+		u.SliceFormat = string(seed.SliceFormatHLS)
+		u.SegmentFile = segmentPattern(segment)
+	}
+	return true
+}
+
+func isDirObject(obj *model.VideoObject) bool {
+	for _, link := range obj.Links {
+		if link.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// isVideoHead check the containers not detected by http.DetectContentType
+func isVideoHead(head []byte) bool {
+	if len(head) >= 8 && string(head[4:8]) == "ftyp" {
+		return true
+	}
+	//matroska
+	if len(head) >= 4 && head[0] == 0x1A && head[1] == 0x45 && head[2] == 0xDF && head[3] == 0xA3 {
+		return true
+	}
+	//mpeg-ts
+	return len(head) >= 189 && head[0] == 0x47 && head[188] == 0x47
+}
+
+// segmentPattern media-00000.ts => media-%05d.ts
+func segmentPattern(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	i := len(base)
+	for i > 0 && base[i-1] >= '0' && base[i-1] <= '9' {
+		i--
+	}
+	if i == len(base) {
+		return name
+	}
+	return base[:i] + "%0" + strconv.Itoa(len(base)-i) + "d" + ext
+}
+
+func catCID(ctx context.Context, api *httpapi.HttpApi, p string, length int64) ([]byte, error) {
+	resp, e := api.Request("cat", p).Option("length", length).Send(ctx)
+	if e != nil {
+		return nil, e
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	defer resp.Close()
+	return ioutil.ReadAll(io.LimitReader(resp.Output, length))
+}
+
+// probeSlice get the resolution from the master playlist,or probe the first segment
+func probeSlice(ctx context.Context, api *httpapi.HttpApi, hash string, m3u8 string) (string, error) {
+	playlist, e := catCID(ctx, api, hash+"/"+m3u8, 1<<20)
+	if e != nil {
+		return "", e
+	}
+	if height := maxHeight(resolutionRegexp, playlist); height > 0 {
+		return strconv.Itoa(height) + "P", nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(playlist)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return probeCID(ctx, api, hash+"/"+line)
+	}
+	return "", nil
+}
+
+// probeMPD get the highest representation of the mpd
+func probeMPD(ctx context.Context, api *httpapi.HttpApi, hash string, mpd string) (string, error) {
+	manifest, e := catCID(ctx, api, hash+"/"+mpd, 1<<20)
+	if e != nil {
+		return "", e
+	}
+	if height := maxHeight(heightRegexp, manifest); height > 0 {
+		return strconv.Itoa(height) + "P", nil
+	}
+	return "", nil
+}
+
+// maxHeight the max height matched in data,the ladder playlists list the lowest rung first
+func maxHeight(re *regexp.Regexp, data []byte) int {
+	height := 0
+	for _, m := range re.FindAllSubmatch(data, -1) {
+		if h, e := strconv.Atoi(string(m[1])); e == nil && h > height {
+			height = h
+		}
+	}
+	return height
+}
+
+// probeCID fetch the head of the file and probe the resolution
+func probeCID(ctx context.Context, api *httpapi.HttpApi, p string) (string, error) {
+	data, e := catCID(ctx, api, p, cidProbeLen)
+	if e != nil {
+		return "", e
+	}
+	file, e := ioutil.TempFile("", "probe")
+	if e != nil {
+		return "", e
+	}
+	defer os.Remove(file.Name())
+	_, e = file.Write(data)
+	file.Close()
+	if e != nil {
+		return "", e
+	}
+	format, e := cmd.FFProbeStreamFormat(file.Name())
+	if e != nil {
+		return "", e
+	}
+	return format.Resolution() + "P", nil
+}
+
+var _ seed.APICaller = &cidImport{}
@@ -0,0 +1,112 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+)
+
+const (
+	cidSlice  = "QmPZ9gcCEpqKTo6aq61g2nXGUhM4iCL3ewB6LDXZCtioEB"
+	cidPoster = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	cidTS     = "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+)
+
+// TestInspectCID ...
+func TestInspectCID(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/ls", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("arg") != "/ipfs/"+cidSlice {
+			return
+		}
+		for _, name := range []string{"media.m3u8", "media-00000.ts"} {
+			_, _ = fmt.Fprintf(w, `{"Objects":[{"Hash":"%s","Links":[{"Name":"%s","Hash":"%s","Size":10,"Type":2}]}]}`+"\n",
+				cidSlice, name, cidTS)
+		}
+	})
+	mux.HandleFunc("/api/v0/object/stat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"Hash":"%s","CumulativeSize":1000}`, cidSlice)
+	})
+	mux.HandleFunc("/api/v0/cat", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("arg") {
+		case cidSlice + "/media.m3u8":
+			_, _ = fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n360P/media.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080\n1080P/media.m3u8\n")
+		case cidPoster:
+			_, _ = w.Write([]byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	u, e := InspectCID(context.Background(), api, cidSlice)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if u.Type != model.TypeSlice || u.M3U8 != "media.m3u8" || u.SegmentFile != "media-%05d.ts" ||
+		u.Sharpness != "1080P" || len(u.Object.Links) != 2 || u.Object.Link.Size != 1000 || u.Checksum != cidSlice {
+		t.Errorf("%+v", u)
+	}
+	u, e = InspectCID(context.Background(), api, cidPoster)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if u.Type != model.TypePoster {
+		t.Errorf("%+v", u)
+	}
+}
+
+// TestSliceLayout ...
+func TestSliceLayout(t *testing.T) {
+	tests := []struct {
+		names   []string
+		format  seed.SliceFormat
+		m3u8    string
+		mpd     string
+		segment string
+	}{
+		{[]string{"media.m3u8", "media-00000.ts"}, seed.SliceFormatHLS, "media.m3u8", "", "media-%05d.ts"},
+		{[]string{"init.mp4", "media-00000.m4s", "media.m3u8"}, seed.SliceFormatFMP4, "media.m3u8", "", "media-%05d.m4s"},
+		{[]string{"chunk-0-00001.m4s", "init-0.m4s", "manifest.mpd"}, seed.SliceFormatDASH, "", "manifest.mpd", seed.SliceFormatDASH.SegmentFile()},
+		{[]string{"chunk-0-00001.m4s", "manifest.mpd", "master.m3u8"}, seed.SliceFormatCMAF, "master.m3u8", "manifest.mpd", seed.SliceFormatCMAF.SegmentFile()},
+	}
+	for _, tt := range tests {
+		obj := new(model.VideoObject)
+		for _, name := range tt.names {
+			obj.Links = append(obj.Links, &model.VideoLink{Name: name})
+		}
+		u := new(model.Unfinished)
+		if !sliceLayout(u, obj) {
+			t.Errorf("%v:not detected", tt.names)
+			continue
+		}
+		if u.Type != model.TypeSlice || u.SliceFormat != string(tt.format) || u.M3U8 != tt.m3u8 || u.MPD != tt.mpd || u.SegmentFile != tt.segment {
+			t.Errorf("%v:%+v", tt.names, u)
+		}
+	}
+	obj := &model.VideoObject{Links: []*model.VideoLink{{Name: "poster.jpg"}, {Name: "media-00000.ts"}}}
+	if sliceLayout(new(model.Unfinished), obj) {
+		t.Error("unsupported layout detected")
+	}
+}
+
+// TestProbeMPD ...
+func TestProbeMPD(t *testing.T) {
+	mpd := `<AdaptationSet id="0" contentType="video">
+<Representation id="0" mimeType="video/mp4" bandwidth="800000" width="640" height="360"/>
+<Representation id="1" mimeType="video/mp4" bandwidth="5000000" width="1920" height="1080"/>
+</AdaptationSet>`
+	if h := maxHeight(heightRegexp, []byte(mpd)); h != 1080 {
+		t.Error(h)
+	}
+}
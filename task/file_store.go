@@ -0,0 +1,147 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/xormsharp/xorm"
+)
+
+// FileStoreStatus ...
+type FileStoreStatus string
+
+// FileStoreStatusOK ...
+const FileStoreStatusOK FileStoreStatus = "ok"
+
+// FileStoreStatusMoved the source file is found at other path
+const FileStoreStatusMoved FileStoreStatus = "moved"
+
+// FileStoreStatusModified the source file is changed
+const FileStoreStatusModified FileStoreStatus = "modified"
+
+// FileStoreStatusMissing the source file is deleted or moved out of the search path
+const FileStoreStatusMissing FileStoreStatus = "missing"
+
+// FileStoreVerify verify the source files backing the filestore(nocopy) hashes
+type FileStoreVerify struct {
+	SearchPath []string //other path to find the moved files,the success dir of moveSuccess is always searched
+	Readd      bool     //re add the moved files,so the filestore reference the new path
+}
+
+// NewFileStoreVerify ...
+func NewFileStoreVerify() *FileStoreVerify {
+	return &FileStoreVerify{}
+}
+
+// Task ...
+func (f *FileStoreVerify) Task() *seed.Task {
+	return seed.NewTask(f)
+}
+
+// CallTask ...
+func (f *FileStoreVerify) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperDatabase, &fileStoreVerify{
+			searchPath: f.SearchPath,
+			readd:      f.Readd,
+		})
+	}
+}
+
+type fileStoreVerify struct {
+	searchPath []string
+	readd      bool
+}
+
+// Call ...
+func (f *fileStoreVerify) Call(database *seed.Database, eng *xorm.Engine) (e error) {
+	stores, e := model.AllFileStore(eng.Where(""), 0)
+	if e != nil {
+		return e
+	}
+	count := make(map[FileStoreStatus]int)
+	for _, store := range *stores {
+		status, path := CheckFileStore(store, f.searchPath...)
+		count[status]++
+		switch status {
+		case FileStoreStatusOK:
+			continue
+		case FileStoreStatusMoved:
+			log.With("hash", store.Hash, "path", store.Path, "moved", path).Warn("filestore source moved")
+			if f.readd {
+				e = database.PushTo(readdFileStore(store, path))
+				if e != nil {
+					log.Error(e)
+				}
+			}
+		case FileStoreStatusModified:
+			log.With("hash", store.Hash, "path", store.Path).Warn("filestore source modified")
+		case FileStoreStatusMissing:
+			log.With("hash", store.Hash, "path", store.Path).Warn("filestore source missing")
+		}
+	}
+	log.With("total", len(*stores), "ok", count[FileStoreStatusOK], "moved", count[FileStoreStatusMoved],
+		"modified", count[FileStoreStatusModified], "missing", count[FileStoreStatusMissing]).Info("filestore verify")
+	return nil
+}
+
+// readdFileStore re add the moved file,the old record is moved to the path or removed if the hash is changed
+func readdFileStore(store *model.FileStore, path string) (seed.Stepper, seed.APICaller) {
+	return seed.APICallback(store, func(api *seed.API, ipapi *httpapi.HttpApi, v interface{}) (e error) {
+		store := v.(*model.FileStore)
+		resolved, e := seed.AddFile(api, path, seed.AddTypeArg(model.TypeVideo))
+		if e != nil {
+			return e
+		}
+		info, e := os.Stat(path)
+		if e != nil {
+			return e
+		}
+		hash := model.PinHash(resolved)
+		log.With("hash", hash, "old", store.Hash, "path", path).Info("filestore readd")
+		return api.PushTo(seed.DatabaseCallback(store, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+			store := v.(*model.FileStore)
+			if hash != store.Hash {
+				return model.DeleteFileStore(eng.Where(""), store)
+			}
+			store.Path = path
+			store.Size = info.Size()
+			store.ModTime = info.ModTime()
+			return model.AddOrUpdateFileStore(eng.Where(""), store)
+		}))
+	})
+}
+
+// CheckFileStore check the source file of store,returns the path found when it is moved
+func CheckFileStore(store *model.FileStore, searchPath ...string) (FileStoreStatus, string) {
+	info, e := os.Stat(store.Path)
+	if e == nil {
+		//the mod time is stored in seconds
+		if info.Size() != store.Size || info.ModTime().Unix() != store.ModTime.Unix() {
+			return FileStoreStatusModified, store.Path
+		}
+		return FileStoreStatusOK, store.Path
+	}
+
+	dir, name := filepath.Split(store.Path)
+	//moveSuccess puts the file into the success dir
+	for _, path := range append([]string{filepath.Join(dir, "success")}, searchPath...) {
+		moved := filepath.Join(path, name)
+		info, e := os.Stat(moved)
+		if e != nil {
+			continue
+		}
+		if info.Size() == store.Size {
+			return FileStoreStatusMoved, moved
+		}
+	}
+	return FileStoreStatusMissing, ""
+}
+
+var _ seed.DatabaseCaller = &fileStoreVerify{}
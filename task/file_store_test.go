@@ -0,0 +1,66 @@
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glvd/seed/model"
+)
+
+// TestCheckFileStore ...
+func TestCheckFileStore(t *testing.T) {
+	dir, e := ioutil.TempDir("", "filestore")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ABP-123.mp4")
+	e = ioutil.WriteFile(file, []byte("video"), 0644)
+	if e != nil {
+		t.Fatal(e)
+	}
+	modTime := time.Unix(1600000000, 123456789)
+	e = os.Chtimes(file, modTime, modTime)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	//the database drops the sub second of the mod time
+	store := &model.FileStore{Hash: "QmA", Path: file, Size: 5, ModTime: time.Unix(modTime.Unix(), 0)}
+	if status, path := CheckFileStore(store); status != FileStoreStatusOK || path != file {
+		t.Error(status, path)
+	}
+	store.ModTime = modTime.Add(-time.Minute)
+	if status, _ := CheckFileStore(store); status != FileStoreStatusModified {
+		t.Error(status)
+	}
+	store.ModTime = modTime
+	store.Size = 6
+	if status, _ := CheckFileStore(store); status != FileStoreStatusModified {
+		t.Error(status)
+	}
+
+	store.Size = 5
+	e = os.MkdirAll(filepath.Join(dir, "success"), os.ModePerm)
+	if e != nil {
+		t.Fatal(e)
+	}
+	moved := filepath.Join(dir, "success", "ABP-123.mp4")
+	e = os.Rename(file, moved)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if status, path := CheckFileStore(store); status != FileStoreStatusMoved || path != moved {
+		t.Error(status, path)
+	}
+	e = os.Remove(moved)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if status, _ := CheckFileStore(store); status != FileStoreStatusMissing {
+		t.Error(status)
+	}
+}
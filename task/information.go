@@ -2,7 +2,6 @@ package task
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -14,9 +13,7 @@ import (
 
 	"github.com/glvd/seed"
 	"github.com/glvd/seed/model"
-	files "github.com/ipfs/go-ipfs-files"
 	httpapi "github.com/ipfs/go-ipfs-http-client"
-	"github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/xormsharp/xorm"
 )
 
@@ -196,21 +193,14 @@ func addThumbHash(a *seed.API, api *httpapi.HttpApi, source *VideoSource) (unf *
 	if a.Failed() {
 		return nil, errors.New("ipfs failed")
 	}
-	file, e := os.Open(source.Thumb)
-	if e != nil {
-		return nil, e
-	}
-	resolved, e := api.Unixfs().Add(context.Background(), files.NewReaderFile(file), func(settings *options.UnixfsAddSettings) error {
-		settings.Pin = true
-		return nil
-	})
+	unfinThumb := defaultUnfinished(source.Thumb)
+	unfinThumb.Type = model.TypeThumb
+	unfinThumb.Relate = source.Bangumi
+	resolved, e := seed.AddFile(a, source.Thumb, seed.AddUnfinishedArg(unfinThumb))
 	if e != nil {
 		a.SetFailed(true)
 		return nil, e
 	}
-	unfinThumb := defaultUnfinished(source.Thumb)
-	unfinThumb.Type = model.TypeThumb
-	unfinThumb.Relate = source.Bangumi
 	if source.Thumb != "" {
 		unfinThumb.Hash = model.PinHash(resolved)
 		e = a.PushTo(seed.DatabaseCallback(unfinThumb, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
@@ -230,20 +220,15 @@ func addPosterHash(a *seed.API, api *httpapi.HttpApi, source *VideoSource) (unf
 		return nil, errors.New("ipfs failed")
 	}
 
-	file, err := os.Open(source.PosterPath)
-	if err != nil {
-		return nil, err
-	}
-	resolved, err := api.Unixfs().Add(context.Background(), files.NewReaderFile(file))
+	unfinPoster := defaultUnfinished(source.PosterPath)
+	unfinPoster.Type = model.TypePoster
+	unfinPoster.Relate = source.Bangumi
+	resolved, err := seed.AddFile(a, source.PosterPath, seed.AddUnfinishedArg(unfinPoster))
 	if err != nil {
 		a.SetFailed(true)
 		return nil, err
 	}
 
-	unfinPoster := defaultUnfinished(source.PosterPath)
-	unfinPoster.Type = model.TypePoster
-	unfinPoster.Relate = source.Bangumi
-
 	if source.PosterPath != "" {
 		unfinPoster.Hash = model.PinHash(resolved)
 		e = a.PushTo(seed.DatabaseCallback(unfinPoster, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
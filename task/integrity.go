@@ -0,0 +1,229 @@
+package task
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	files "github.com/ipfs/go-ipfs-files"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/xormsharp/xorm"
+)
+
+// integrityTypes the types with the checksum of the original file,
+// the preview keeps the checksum of the source and is not verified
+var integrityTypes = []model.Type{model.TypeVideo, model.TypePoster, model.TypeThumb}
+
+// IntegrityMismatch ...
+type IntegrityMismatch struct {
+	Hash     string     `json:"hash"`
+	Type     model.Type `json:"type"`
+	Relate   string     `json:"relate"`
+	Checksum string     `json:"checksum"`
+	Actual   string     `json:"actual"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// IntegrityResult ...
+type IntegrityResult struct {
+	Checked  int                  `json:"checked"`
+	Skipped  int                  `json:"skipped"`
+	Failed   int                  `json:"failed"`
+	Mismatch []*IntegrityMismatch `json:"mismatch"`
+}
+
+// Integrity verify the content of the hash matches the checksum of the original file
+type Integrity struct {
+	Types    []model.Type
+	Sample   float64       //verify the sampled rate of the hashes,1 to verify all
+	Rate     int64         //read bytes per second,0 is unlimited
+	Interval time.Duration //run again after the interval,0 to run once
+	Limit    int
+	Output   string //write the result to the json file
+	running  int32  //a verification is running
+}
+
+// NewIntegrity ...
+func NewIntegrity() *Integrity {
+	return &Integrity{
+		Types:  integrityTypes,
+		Sample: 1,
+	}
+}
+
+// Task ...
+func (i *Integrity) Task() *seed.Task {
+	return seed.NewTask(i)
+}
+
+// CallTask ...
+func (i *Integrity) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		e := seeder.PushTo(seed.StepperAPI, &integrityCall{integrity: i})
+		if e != nil || i.Interval <= 0 {
+			return e
+		}
+		go func() {
+			ticker := time.NewTicker(i.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-seeder.Context().Done():
+					return
+				case <-ticker.C:
+					e := seeder.PushTo(seed.StepperAPI, &integrityCall{integrity: i})
+					if e != nil {
+						log.Error(e)
+					}
+				}
+			}
+		}()
+		return nil
+	}
+}
+
+type integrityCall struct {
+	integrity *Integrity
+}
+
+// Call ...
+func (c *integrityCall) Call(a *seed.API, api *httpapi.HttpApi) error {
+	types := make([]interface{}, 0, len(c.integrity.Types))
+	for _, t := range c.integrity.Types {
+		types = append(types, t)
+	}
+	u := make(chan *model.Unfinished)
+	e := a.PushTo(seed.DatabaseUnfinishedCall(u, func(session *xorm.Session) *xorm.Session {
+		session = session.Where("hash <> ?", "").In("type", types...)
+		if c.integrity.Limit > 0 {
+			session = session.Limit(c.integrity.Limit)
+		}
+		return session
+	}))
+	if e != nil {
+		return e
+	}
+	var unfins []*model.Unfinished
+	for unfin := range u {
+		if unfin == nil {
+			break
+		}
+		unfins = append(unfins, unfin)
+	}
+
+	//the verification is rate limited,the api thread only dispatches it
+	if !atomic.CompareAndSwapInt32(&c.integrity.running, 0, 1) {
+		log.With("count", len(unfins)).Warn("integrity is running")
+		return nil
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.integrity.running, 0)
+		e := c.integrity.verify(a.Context(), api, unfins)
+		if e != nil {
+			log.Error(e)
+		}
+	}()
+	return nil
+}
+
+// verify verify the content of the unfinished hashes and write the result
+func (i *Integrity) verify(ctx context.Context, api *httpapi.HttpApi, unfins []*model.Unfinished) error {
+	result := &IntegrityResult{Mismatch: []*IntegrityMismatch{}}
+	for _, unfin := range unfins {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+		//the checksum of the cid imported content is the cid,the preview keeps the checksum of the source
+		if !isSHA1(unfin.Checksum) || unfin.Type == model.TypePreview || (i.Sample < 1 && rand.Float64() >= i.Sample) {
+			result.Skipped++
+			continue
+		}
+		result.Checked++
+		actual, e := ContentChecksum(ctx, api, unfin.Hash, i.Rate)
+		if e == nil && actual == unfin.Checksum {
+			continue
+		}
+		mismatch := &IntegrityMismatch{
+			Hash:     unfin.Hash,
+			Type:     unfin.Type,
+			Relate:   unfin.Relate,
+			Checksum: unfin.Checksum,
+			Actual:   actual,
+		}
+		if e != nil {
+			result.Failed++
+			mismatch.Error = e.Error()
+			log.With("hash", unfin.Hash, "type", unfin.Type, "relate", unfin.Relate).Error(e)
+		} else {
+			log.With("hash", unfin.Hash, "type", unfin.Type, "relate", unfin.Relate, "checksum", unfin.Checksum, "actual", actual).Warn("integrity mismatch")
+		}
+		result.Mismatch = append(result.Mismatch, mismatch)
+	}
+	log.With("checked", result.Checked, "skipped", result.Skipped, "failed", result.Failed, "mismatch", len(result.Mismatch)-result.Failed).Info("integrity")
+	if i.Output != "" {
+		return seed.JSONWrite(i.Output, result)
+	}
+	return nil
+}
+
+func isSHA1(s string) bool {
+	b, e := hex.DecodeString(s)
+	return e == nil && len(b) == sha1.Size
+}
+
+// ContentChecksum stream the content of hash and returns the sha1 as model.Checksum,the read is limited to rate bytes per second
+func ContentChecksum(ctx context.Context, api *httpapi.HttpApi, hash string, rate int64) (string, error) {
+	node, e := api.Unixfs().Get(ctx, path.New("/ipfs/"+hash))
+	if e != nil {
+		return "", e
+	}
+	defer node.Close()
+	file, b := node.(files.File)
+	if !b {
+		return "", errors.New("content is not a file")
+	}
+	var reader io.Reader = file
+	if rate > 0 {
+		reader = &rateReader{reader: file, rate: rate, start: time.Now()}
+	}
+	sum := sha1.New()
+	_, e = io.Copy(sum, reader)
+	if e != nil {
+		return "", e
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// rateReader sleep when the read is faster than rate
+type rateReader struct {
+	reader io.Reader
+	rate   int64
+	start  time.Time
+	read   int64
+}
+
+// Read ...
+func (r *rateReader) Read(p []byte) (int, error) {
+	n, e := r.reader.Read(p)
+	r.read += int64(n)
+	expect := time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second))
+	if wait := expect - time.Since(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, e
+}
+
+var _ seed.APICaller = &integrityCall{}
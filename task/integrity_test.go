@@ -0,0 +1,117 @@
+package task
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+)
+
+func integrityServer(t *testing.T, content []byte) (*httptest.Server, *httpapi.HttpApi) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/files/stat", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"Hash":"%s","Type":"file","Size":%d}`, cidPoster, len(content))
+	})
+	mux.HandleFunc("/api/v0/cat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	})
+	srv := httptest.NewServer(mux)
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		srv.Close()
+		t.Fatal(e)
+	}
+	return srv, api
+}
+
+// TestContentChecksum ...
+func TestContentChecksum(t *testing.T) {
+	content := []byte("integrity content")
+	srv, api := integrityServer(t, content)
+	defer srv.Close()
+
+	sum := sha1.Sum(content)
+	start := time.Now()
+	actual, e := ContentChecksum(context.Background(), api, cidPoster, int64(len(content))*4)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if actual != hex.EncodeToString(sum[:]) {
+		t.Errorf("checksum %s", actual)
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("rate is not limited: %v", time.Since(start))
+	}
+	if !isSHA1(actual) || isSHA1(cidPoster) {
+		t.Error("isSHA1")
+	}
+}
+
+// TestIntegrityVerify ...
+func TestIntegrityVerify(t *testing.T) {
+	content := []byte("integrity content")
+	srv, api := integrityServer(t, content)
+	defer srv.Close()
+	dir, e := ioutil.TempDir("", "integrity")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	sum := sha1.Sum(content)
+	integrity := NewIntegrity()
+	integrity.Output = filepath.Join(dir, "integrity.json")
+	e = integrity.verify(context.Background(), api, []*model.Unfinished{
+		{Hash: cidPoster, Type: model.TypePoster, Checksum: hex.EncodeToString(sum[:])},
+		{Hash: cidPoster, Type: model.TypeThumb, Checksum: strings.Repeat("0", 40)},
+		{Hash: cidSlice, Type: model.TypeVideo, Checksum: cidSlice},
+		{Hash: cidPoster, Type: model.TypePreview, Checksum: strings.Repeat("1", 40)},
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	result := new(IntegrityResult)
+	data, e := ioutil.ReadFile(integrity.Output)
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = json.Unmarshal(data, result)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if result.Checked != 2 || result.Skipped != 2 || result.Failed != 0 || len(result.Mismatch) != 1 ||
+		result.Mismatch[0].Type != model.TypeThumb || result.Mismatch[0].Actual != hex.EncodeToString(sum[:]) {
+		t.Errorf("%+v", result)
+	}
+}
+
+// TestRateReader ...
+func TestRateReader(t *testing.T) {
+	//10GB read at 1GB/s is expected at 10s,50ms left to wait
+	r := &rateReader{
+		reader: strings.NewReader("x"),
+		rate:   1e9,
+		start:  time.Now().Add(-10*time.Second + 50*time.Millisecond),
+		read:   1e10 - 1,
+	}
+	start := time.Now()
+	if _, e := r.Read(make([]byte, 1)); e != nil {
+		t.Fatal(e)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond || d > time.Second {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,179 @@
+package task
+
+import (
+	"context"
+	gopath "path"
+	"strings"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/xormsharp/xorm"
+)
+
+// DefaultLibraryRoot ...
+const DefaultLibraryRoot = "/library"
+
+// Library mirror the video catalog into the mfs tree:
+// <root>/<bangumi>/[S<season>/E<episode>/]{source,hls,poster,thumb}
+type Library struct {
+	Root    string
+	Bangumi []interface{} //sync the bangumi only,empty to sync all
+	Key     string        //publish the root under the ipns key,empty to skip
+}
+
+// NewLibrary ...
+func NewLibrary() *Library {
+	return &Library{
+		Root: DefaultLibraryRoot,
+	}
+}
+
+// Task ...
+func (l *Library) Task() *seed.Task {
+	return seed.NewTask(l)
+}
+
+// CallTask ...
+func (l *Library) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperAPI, &librarySync{
+			root:    l.Root,
+			bangumi: l.Bangumi,
+			key:     l.Key,
+		})
+	}
+}
+
+type librarySync struct {
+	root    string
+	bangumi []interface{}
+	key     string
+}
+
+// Call ...
+func (l *librarySync) Call(a *seed.API, api *httpapi.HttpApi) error {
+	v := make(chan *model.Video)
+	e := a.PushTo(seed.DatabaseVideoCall(v, func(session *xorm.Session) *xorm.Session {
+		if len(l.bangumi) > 0 {
+			return session.In("bangumi", l.bangumi...)
+		}
+		return session
+	}))
+	if e != nil {
+		return e
+	}
+	var videos []*model.Video
+	for video := range v {
+		if video == nil {
+			break
+		}
+		videos = append(videos, video)
+	}
+
+	failed := 0
+	for _, video := range videos {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		dir := LibraryPath(l.root, video)
+		for name, hash := range libraryEntries(video) {
+			e := LibraryLink(a.Context(), api, gopath.Join(dir, name), hash)
+			if e != nil {
+				failed++
+				log.With("bangumi", video.Bangumi, "name", name, "hash", hash).Error(e)
+			}
+		}
+	}
+	root, e := filesStat(a.Context(), api, l.root)
+	if e != nil {
+		return e
+	}
+	log.With("root", l.root, "hash", root, "videos", len(videos), "failed", failed).Info("library synced")
+	if l.key != "" {
+		if _, e := catalogKey(a.Context(), api, l.key); e != nil {
+			return e
+		}
+		_, e = api.Name().Publish(a.Context(), path.New("/ipfs/"+root), options.Name.Key(l.key), options.Name.AllowOffline(true))
+		if e != nil {
+			return e
+		}
+		log.With("key", l.key, "hash", root).Info("library published")
+	}
+	return nil
+}
+
+// LibraryPath the mfs dir of the video
+func LibraryPath(root string, video *model.Video) string {
+	dir := gopath.Join(root, libraryName(video.Bangumi))
+	if video.Season != "" {
+		dir = gopath.Join(dir, "S"+libraryName(video.Season))
+	}
+	if video.Episode != "" {
+		dir = gopath.Join(dir, "E"+libraryName(video.Episode))
+	}
+	return dir
+}
+
+// libraryName clean the name used in the mfs path
+func libraryName(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "/", "_"))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+func libraryEntries(video *model.Video) map[string]string {
+	entries := make(map[string]string)
+	for name, hash := range map[string]string{
+		"source":  video.SourceHash,
+		"hls":     video.M3U8Hash,
+		"poster":  video.PosterHash,
+		"thumb":   video.ThumbHash,
+		"preview": video.PreviewHash,
+	} {
+		if hash != "" {
+			entries[name] = hash
+		}
+	}
+	return entries
+}
+
+// LibraryLink copy the hash to the mfs path,the old entry is replaced when the hash changed
+func LibraryLink(ctx context.Context, api *httpapi.HttpApi, p string, hash string) error {
+	if exist, e := filesStat(ctx, api, p); e == nil {
+		if exist == hash {
+			return nil
+		}
+		e = api.Request("files/rm", p).Option("recursive", true).Exec(ctx, nil)
+		if e != nil {
+			return e
+		}
+	}
+	e := api.Request("files/mkdir", gopath.Dir(p)).Option("parents", true).Exec(ctx, nil)
+	if e != nil {
+		return e
+	}
+	return api.Request("files/cp", "/ipfs/"+hash, p).Exec(ctx, nil)
+}
+
+func filesStat(ctx context.Context, api *httpapi.HttpApi, p string) (string, error) {
+	var stat struct {
+		Hash string
+	}
+	e := api.Request("files/stat", p).Option("hash", true).Exec(ctx, &stat)
+	if e != nil {
+		return "", e
+	}
+	return stat.Hash, nil
+}
+
+var _ seed.APICaller = &librarySync{}
@@ -0,0 +1,67 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+)
+
+// TestLibraryPath ...
+func TestLibraryPath(t *testing.T) {
+	if p := LibraryPath(DefaultLibraryRoot, &model.Video{Bangumi: "ABP-123"}); p != "/library/ABP-123" {
+		t.Error(p)
+	}
+	if p := LibraryPath(DefaultLibraryRoot, &model.Video{Bangumi: "ABP/123", Season: "1", Episode: "02"}); p != "/library/ABP_123/S1/E02" {
+		t.Error(p)
+	}
+}
+
+// TestLibraryLink ...
+func TestLibraryLink(t *testing.T) {
+	files := map[string]string{"/library/ABP-123/hls": "QmOld"}
+	var commands []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/files/", func(w http.ResponseWriter, r *http.Request) {
+		args := r.URL.Query()["arg"]
+		commands = append(commands, r.URL.Path[len("/api/v0/"):])
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v0/files/stat":
+			hash, b := files[args[0]]
+			if !b {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = fmt.Fprint(w, `{"Message":"file does not exist","Code":0}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"Hash":"%s"}`, hash)
+		case "/api/v0/files/rm":
+			delete(files, args[0])
+		case "/api/v0/files/cp":
+			files[args[1]] = args[0][len("/ipfs/"):]
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	api, e := httpapi.NewURLApiWithClient(srv.URL, http.DefaultClient)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = LibraryLink(context.Background(), api, "/library/ABP-123/hls", "QmOld")
+	if e != nil || len(commands) != 1 {
+		t.Error(commands, e)
+	}
+	commands = nil
+	e = LibraryLink(context.Background(), api, "/library/ABP-123/hls", "QmNew")
+	if e != nil || files["/library/ABP-123/hls"] != "QmNew" {
+		t.Error(files, e)
+	}
+	if fmt.Sprint(commands) != "[files/stat files/rm files/mkdir files/cp]" {
+		t.Error(commands)
+	}
+}
@@ -14,7 +14,6 @@ import (
 	httpapi "github.com/ipfs/go-ipfs-http-client"
 	iface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
-	"github.com/ipfs/interface-go-ipfs-core/path"
 )
 
 // Pin ...
@@ -171,10 +170,7 @@ ChanEnd:
 			}
 			if !seed.SkipTypeVerify(unfinished.Type, p.skip...) {
 				log.With("type", unfinished.Type, "hash", unfinished.Hash).Info("pinning")
-				e := api.Pin().Add(a.Context(), path.New(unfinished.Hash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				e := seed.PinAdd(a, api, unfinished.Hash)
 				if e != nil {
 					log.Error(e)
 					break ChanEnd
@@ -202,10 +198,7 @@ ChanEnd:
 			}
 			if !seed.SkipVerify("source", p.skip...) && video.SourceHash != "" {
 				log.With("hash", video.SourceHash).Info("source pinning")
-				e := api.Pin().Add(a.Context(), path.New(video.SourceHash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				e := seed.PinAdd(a, api, video.SourceHash)
 				if e != nil {
 					log.Error(e)
 					break ChanEnd
@@ -213,10 +206,7 @@ ChanEnd:
 			}
 			if !seed.SkipVerify("slice", p.skip...) && video.M3U8Hash != "" {
 				log.With("hash", video.M3U8Hash).Info("slice pinning")
-				e := api.Pin().Add(a.Context(), path.New(video.M3U8Hash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				e := seed.PinAdd(a, api, video.M3U8Hash)
 				if e != nil {
 					log.Error(e)
 					break ChanEnd
@@ -224,10 +214,7 @@ ChanEnd:
 			}
 			if !seed.SkipVerify("poster", p.skip...) && video.PosterHash != "" {
 				log.With("hash", video.PosterHash).Info("poster pinning")
-				e := api.Pin().Add(a.Context(), path.New(video.PosterHash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				e := seed.PinAdd(a, api, video.PosterHash)
 				if e != nil {
 					log.Error(e)
 					break ChanEnd
@@ -235,10 +222,7 @@ ChanEnd:
 			}
 			if !seed.SkipVerify("thumb", p.skip...) && video.ThumbHash != "" {
 				log.With("hash", video.ThumbHash).Info("thumb pinning")
-				e := api.Pin().Add(a.Context(), path.New(video.ThumbHash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				e := seed.PinAdd(a, api, video.ThumbHash)
 				if e != nil {
 					log.Error(e)
 					break ChanEnd
@@ -560,10 +544,7 @@ ChanEnd:
 			}
 			if i > 0 {
 				log.With("hash", pin.PinHash, "peer_id", pin.PeerID, "video", (*vs)[0].Bangumi).Info("pinning")
-				err := api.Pin().Add(a.Context(), path.New(pin.PinHash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				err := seed.PinAdd(a, api, pin.PinHash)
 				if err != nil {
 					log.Error(err)
 				}
@@ -625,10 +606,7 @@ ChanEnd:
 			}
 			if i > 0 {
 				log.With("hash", pin.PinHash, "peer_id", pin.PeerID, "type", (*us)[0].Type, "relate", (*us)[0].Relate).Info("pinning")
-				err := api.Pin().Add(a.Context(), path.New(pin.PinHash), func(settings *options.PinAddSettings) error {
-					settings.Recursive = true
-					return nil
-				})
+				err := seed.PinAdd(a, api, pin.PinHash)
 				if err != nil {
 					log.Error(err)
 				}
@@ -665,10 +643,7 @@ ChanEnd:
 				break ChanEnd
 			}
 			log.With("hash", pin.PinHash, "peer_id", pin.PeerID).Info("pinning")
-			err := api.Pin().Add(a.Context(), path.New(pin.PinHash), func(settings *options.PinAddSettings) error {
-				settings.Recursive = true
-				return nil
-			})
+			err := seed.PinAdd(a, api, pin.PinHash)
 			if err != nil {
 				log.Error(err)
 			}
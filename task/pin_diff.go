@@ -0,0 +1,387 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/xormsharp/xorm"
+)
+
+// PinDiffDirection ...
+type PinDiffDirection string
+
+// PinDiffDirectionNone only report the diff
+const PinDiffDirectionNone PinDiffDirection = "none"
+
+// PinDiffDirectionFromTo pin the hashes only From has onto To
+const PinDiffDirectionFromTo PinDiffDirection = "from_to"
+
+// PinDiffDirectionToFrom pin the hashes only To has onto From
+const PinDiffDirectionToFrom PinDiffDirection = "to_from"
+
+// PinDiffDirectionBoth pin the hashes both side lacks onto the side
+const PinDiffDirectionBoth PinDiffDirection = "both"
+
+// pinDiffTypes the asset types compared by diff
+var pinDiffTypes = []model.Type{model.TypeSlice, model.TypeVideo, model.TypePoster, model.TypeThumb, model.TypePreview}
+
+// PinDiff diff the pin set between two seed nodes
+type PinDiff struct {
+	From      string       //multiaddr of the from peer
+	To        string       //multiaddr of the to peer
+	FromDB    *xorm.Engine //database snapshot of the from node
+	ToDB      *xorm.Engine //database snapshot of the to node
+	FromAPI   string       //multiaddr of the http api of the from node,the hashes only To has are pinned by it
+	ToAPI     string       //multiaddr of the http api of the to node,the hashes only From has are pinned by it
+	SkipType  []interface{}
+	Direction PinDiffDirection
+	Output    string //report output json path
+}
+
+// PinDiffSet ...
+type PinDiffSet map[model.Type]map[string]bool
+
+// PinDiffTypeResult ...
+type PinDiffTypeResult struct {
+	Missing []string `json:"missing"` //From has,To lacks
+	Extra   []string `json:"extra"`   //To has,From lacks
+}
+
+// PinDiffResult ...
+type PinDiffResult struct {
+	From  string                            `json:"from"`
+	To    string                            `json:"to"`
+	Types map[model.Type]*PinDiffTypeResult `json:"types"`
+}
+
+// NewPinDiff ...
+func NewPinDiff(from, to string) *PinDiff {
+	return &PinDiff{
+		From:      from,
+		To:        to,
+		Direction: PinDiffDirectionNone,
+	}
+}
+
+// Task ...
+func (p *PinDiff) Task() *seed.Task {
+	return seed.NewTask(p)
+}
+
+// CallTask ...
+func (p *PinDiff) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		diff := &pinDiffCall{
+			from:      p.From,
+			to:        p.To,
+			fromDB:    p.FromDB,
+			toDB:      p.ToDB,
+			fromAPI:   p.FromAPI,
+			toAPI:     p.ToAPI,
+			skip:      p.SkipType,
+			direction: p.Direction,
+			output:    p.Output,
+		}
+		return seeder.PushTo(seed.StepperDatabase, diff)
+	}
+}
+
+type pinDiffCall struct {
+	from      string
+	to        string
+	fromDB    *xorm.Engine
+	toDB      *xorm.Engine
+	fromAPI   string
+	toAPI     string
+	skip      []interface{}
+	direction PinDiffDirection
+	output    string
+}
+
+// Call ...
+func (p *pinDiffCall) Call(database *seed.Database, eng *xorm.Engine) (e error) {
+	var from, to PinDiffSet
+	if p.fromDB != nil && p.toDB != nil {
+		from, e = snapshotPinSet(p.fromDB, p.skip)
+		if e != nil {
+			return e
+		}
+		to, e = snapshotPinSet(p.toDB, p.skip)
+		if e != nil {
+			return e
+		}
+	} else {
+		from, e = peerPinSet(eng, addrPeerID(p.from), p.skip)
+		if e != nil {
+			return e
+		}
+		to, e = peerPinSet(eng, addrPeerID(p.to), p.skip)
+		if e != nil {
+			return e
+		}
+	}
+
+	result := DiffPinSet(from, to)
+	result.From = p.from
+	result.To = p.to
+	for _, t := range pinDiffTypes {
+		if r, b := result.Types[t]; b {
+			log.With("type", t, "missing", len(r.Missing), "extra", len(r.Extra)).Info("pin diff")
+		}
+	}
+	if p.output != "" {
+		e = seed.JSONWrite(p.output, result)
+		if e != nil {
+			return e
+		}
+	}
+
+	if p.direction == PinDiffDirectionFromTo || p.direction == PinDiffDirectionBoth {
+		e = database.PushTo(seed.StepperAPI, &pinDiffReconcile{from: p.from, to: p.to, toAPI: p.toAPI, hashes: result.missing()})
+		if e != nil {
+			return e
+		}
+	}
+	if p.direction == PinDiffDirectionToFrom || p.direction == PinDiffDirectionBoth {
+		e = database.PushTo(seed.StepperAPI, &pinDiffReconcile{from: p.to, to: p.from, toAPI: p.fromAPI, hashes: result.extra()})
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// DiffPinSet ...
+func DiffPinSet(from, to PinDiffSet) *PinDiffResult {
+	result := &PinDiffResult{
+		Types: make(map[model.Type]*PinDiffTypeResult),
+	}
+	for _, t := range pinDiffTypes {
+		r := &PinDiffTypeResult{
+			Missing: []string{},
+			Extra:   []string{},
+		}
+		for hash := range from[t] {
+			if !to[t][hash] {
+				r.Missing = append(r.Missing, hash)
+			}
+		}
+		for hash := range to[t] {
+			if !from[t][hash] {
+				r.Extra = append(r.Extra, hash)
+			}
+		}
+		sort.Strings(r.Missing)
+		sort.Strings(r.Extra)
+		result.Types[t] = r
+	}
+	return result
+}
+
+func (r *PinDiffResult) missing() (hashes []string) {
+	for _, t := range pinDiffTypes {
+		if v, b := r.Types[t]; b {
+			hashes = append(hashes, v.Missing...)
+		}
+	}
+	return
+}
+
+func (r *PinDiffResult) extra() (hashes []string) {
+	for _, t := range pinDiffTypes {
+		if v, b := r.Types[t]; b {
+			hashes = append(hashes, v.Extra...)
+		}
+	}
+	return
+}
+
+func (s PinDiffSet) add(t model.Type, hash string, skip []interface{}) {
+	if hash == "" || seed.SkipTypeVerify(t, skip...) {
+		return
+	}
+	if _, b := s[t]; !b {
+		s[t] = make(map[string]bool)
+	}
+	s[t][hash] = true
+}
+
+func (s PinDiffSet) addVideo(video *model.Video, skip []interface{}) {
+	s.add(model.TypeSlice, video.M3U8Hash, skip)
+	s.add(model.TypeVideo, video.SourceHash, skip)
+	s.add(model.TypePoster, video.PosterHash, skip)
+	s.add(model.TypeThumb, video.ThumbHash, skip)
+	s.add(model.TypePreview, video.PreviewHash, skip)
+}
+
+// addrPeerID get the peer id from a p2p multiaddr
+func addrPeerID(addr string) string {
+	idx := strings.LastIndex(addr, "/") + 1
+	return addr[idx:]
+}
+
+// peerPinSet collect the pins recorded for peer and classify them by the video and unfinished tables
+func peerPinSet(eng *xorm.Engine, peerID string, skip []interface{}) (PinDiffSet, error) {
+	set := make(PinDiffSet)
+	rows, e := eng.Where("peer_id = ?", peerID).Rows(&model.Pin{})
+	if e != nil {
+		return nil, e
+	}
+	var pins []string
+	for rows.Next() {
+		pin := new(model.Pin)
+		e = rows.Scan(pin)
+		if e != nil {
+			rows.Close()
+			return nil, e
+		}
+		pins = append(pins, pin.PinHash)
+	}
+	rows.Close()
+
+	for _, hash := range pins {
+		u := new(model.Unfinished)
+		b, e := eng.Where("hash = ?", hash).Get(u)
+		if e != nil {
+			return nil, e
+		}
+		if b {
+			set.add(u.Type, hash, skip)
+			continue
+		}
+		v := new(model.Video)
+		b, e = eng.Where("m3u8_hash = ?", hash).
+			Or("source_hash = ?", hash).
+			Or("poster_hash = ?", hash).
+			Or("thumb_hash = ?", hash).
+			Or("preview_hash = ?", hash).Get(v)
+		if e != nil {
+			return nil, e
+		}
+		if !b {
+			log.With("hash", hash, "peer_id", peerID).Info("unknown pin")
+			continue
+		}
+		switch hash {
+		case v.M3U8Hash:
+			set.add(model.TypeSlice, hash, skip)
+		case v.SourceHash:
+			set.add(model.TypeVideo, hash, skip)
+		case v.PosterHash:
+			set.add(model.TypePoster, hash, skip)
+		case v.ThumbHash:
+			set.add(model.TypeThumb, hash, skip)
+		case v.PreviewHash:
+			set.add(model.TypePreview, hash, skip)
+		}
+	}
+	return set, nil
+}
+
+// snapshotPinSet collect the hashes recorded in a database snapshot
+func snapshotPinSet(eng *xorm.Engine, skip []interface{}) (PinDiffSet, error) {
+	set := make(PinDiffSet)
+	rows, e := eng.Rows(&model.Video{})
+	if e != nil {
+		return nil, e
+	}
+	for rows.Next() {
+		video := new(model.Video)
+		e = rows.Scan(video)
+		if e != nil {
+			rows.Close()
+			return nil, e
+		}
+		set.addVideo(video, skip)
+	}
+	rows.Close()
+
+	rows, e = eng.Where("hash <> ?", "").Rows(&model.Unfinished{})
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u := new(model.Unfinished)
+		e = rows.Scan(u)
+		if e != nil {
+			return nil, e
+		}
+		set.add(u.Type, u.Hash, skip)
+	}
+	return set, nil
+}
+
+// pinDiffReconcile pin the hashes of from onto to
+type pinDiffReconcile struct {
+	from   string
+	to     string
+	toAPI  string
+	hashes []string
+}
+
+// target the http api of to,the local node is used only if it is to
+func (p *pinDiffReconcile) target(a *seed.API, api *httpapi.HttpApi) (*httpapi.HttpApi, error) {
+	if p.toAPI != "" {
+		ma, e := multiaddr.NewMultiaddr(p.toAPI)
+		if e != nil {
+			return nil, e
+		}
+		return httpapi.NewApi(ma)
+	}
+	pid, e := seed.MyID(a)
+	if e != nil {
+		return nil, e
+	}
+	if pid.ID != addrPeerID(p.to) {
+		return nil, fmt.Errorf("the api of %s is not set", p.to)
+	}
+	return api, nil
+}
+
+// Call ...
+func (p *pinDiffReconcile) Call(a *seed.API, api *httpapi.HttpApi) error {
+	total := len(p.hashes)
+	if total == 0 {
+		return nil
+	}
+	target, e := p.target(a, api)
+	if e != nil {
+		return e
+	}
+	if ma, e := multiaddr.NewMultiaddr(p.from); e == nil {
+		if pi, e := peer.AddrInfoFromP2pAddr(ma); e == nil {
+			if e := target.Swarm().Connect(a.Context(), *pi); e != nil {
+				log.With("from", p.from).Error(e)
+			}
+		}
+	}
+
+	failed := 0
+	for i, hash := range p.hashes {
+		select {
+		case <-a.Context().Done():
+			return nil
+		default:
+		}
+		e := seed.PinAdd(a, target, hash)
+		if e != nil {
+			failed++
+			log.With("hash", hash).Error(e)
+		}
+		log.With("from", p.from, "to", p.to, "hash", hash, "done", i+1, "total", total, "failed", failed).Info("reconcile")
+	}
+	return nil
+}
+
+var _ seed.DatabaseCaller = &pinDiffCall{}
+var _ seed.APICaller = &pinDiffReconcile{}
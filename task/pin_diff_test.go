@@ -0,0 +1,84 @@
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glvd/seed/model"
+)
+
+// TestDiffPinSet ...
+func TestDiffPinSet(t *testing.T) {
+	from := make(PinDiffSet)
+	to := make(PinDiffSet)
+	from.add(model.TypeSlice, "QmA", nil)
+	from.add(model.TypeSlice, "QmB", nil)
+	from.add(model.TypePoster, "QmP", nil)
+	from.add(model.TypeThumb, "QmT", []interface{}{"thumb"})
+	to.add(model.TypeSlice, "QmB", nil)
+	to.add(model.TypeVideo, "QmV", nil)
+
+	result := DiffPinSet(from, to)
+	slice := result.Types[model.TypeSlice]
+	if len(slice.Missing) != 1 || slice.Missing[0] != "QmA" || len(slice.Extra) != 0 {
+		t.Error("slice", slice)
+	}
+	video := result.Types[model.TypeVideo]
+	if len(video.Missing) != 0 || len(video.Extra) != 1 || video.Extra[0] != "QmV" {
+		t.Error("video", video)
+	}
+	if len(result.Types[model.TypeThumb].Missing) != 0 {
+		t.Error("thumb should be skipped")
+	}
+	if len(result.missing()) != 2 || len(result.extra()) != 1 {
+		t.Error(result.missing(), result.extra())
+	}
+}
+
+// TestAddrPeerID ...
+func TestAddrPeerID(t *testing.T) {
+	id := addrPeerID("/ip4/192.168.1.13/tcp/14001/ipfs/QmXNZRTd54Zvarf4sswVvUUnpb4gPQNAhFViozVgG8uwri")
+	if id != "QmXNZRTd54Zvarf4sswVvUUnpb4gPQNAhFViozVgG8uwri" {
+		t.Error(id)
+	}
+	if addrPeerID("QmXNZ") != "QmXNZ" {
+		t.Error("plain id")
+	}
+}
+
+// TestPeerPinSet ...
+func TestPeerPinSet(t *testing.T) {
+	dir, e := ioutil.TempDir("", "pin")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	eng, e := model.InitSQLite3(filepath.Join(dir, "pin.db"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer eng.Close()
+	e = eng.Sync2(model.Video{}, model.Unfinished{}, model.Pin{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	e = model.AddOrUpdateVideo(eng.Where(""), &model.Video{Bangumi: "ABP-123", M3U8Hash: "QmSlice", PreviewHash: "QmPreview"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, hash := range []string{"QmSlice", "QmPreview"} {
+		_, e = eng.InsertOne(&model.Pin{PinHash: hash, PeerID: "QmPeer"})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	set, e := peerPinSet(eng, "QmPeer", nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(set[model.TypeSlice]) != 1 || len(set[model.TypePreview]) != 1 {
+		t.Errorf("%+v", set)
+	}
+}
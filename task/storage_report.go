@@ -0,0 +1,282 @@
+package task
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/xormsharp/xorm"
+)
+
+// ReportFormat ...
+type ReportFormat string
+
+// ReportFormatJSON ...
+const ReportFormatJSON ReportFormat = "json"
+
+// ReportFormatCSV ...
+const ReportFormatCSV ReportFormat = "csv"
+
+// StorageEntry the size of a hash
+type StorageEntry struct {
+	Hash      string     `json:"hash"`
+	Type      model.Type `json:"type"`
+	Bangumi   string     `json:"bangumi"`
+	Sharpness string     `json:"sharpness"`
+	Series    string     `json:"series"`
+	Size      uint64     `json:"size"`
+	Peers     []string   `json:"peers,omitempty"`
+}
+
+// StorageGroup ...
+type StorageGroup struct {
+	Count int    `json:"count"`
+	Size  uint64 `json:"size"`
+}
+
+// StorageResult ...
+type StorageResult struct {
+	Total     StorageGroup             `json:"total"`
+	Unknown   int                      `json:"unknown"` //hashes without size
+	Types     map[string]*StorageGroup `json:"types"`
+	Sharpness map[string]*StorageGroup `json:"sharpness"`
+	Series    map[string]*StorageGroup `json:"series"`
+	Peers     map[string]*StorageGroup `json:"peers"`
+	Videos    map[string]*StorageGroup `json:"videos"`
+	Entries   []*StorageEntry          `json:"entries,omitempty"`
+}
+
+// StorageReport report the storage used by video,type,sharpness,series and peer
+type StorageReport struct {
+	Output  string
+	Format  ReportFormat
+	Stat    bool //stat the hashes without recorded size through the api
+	Entries bool //write the size of every hash to the json report
+}
+
+// NewStorageReport ...
+func NewStorageReport(output string) *StorageReport {
+	format := ReportFormatJSON
+	if strings.HasSuffix(output, ".csv") {
+		format = ReportFormatCSV
+	}
+	return &StorageReport{
+		Output: output,
+		Format: format,
+		Stat:   true,
+	}
+}
+
+// Task ...
+func (s *StorageReport) Task() *seed.Task {
+	return seed.NewTask(s)
+}
+
+// CallTask ...
+func (s *StorageReport) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		return seeder.PushTo(seed.StepperDatabase, &storageCollect{
+			report: s,
+		})
+	}
+}
+
+type storageCollect struct {
+	report *StorageReport
+}
+
+// Call ...
+func (s *storageCollect) Call(database *seed.Database, eng *xorm.Engine) (e error) {
+	entries := make(map[string]*StorageEntry)
+	entry := func(hash string, t model.Type) *StorageEntry {
+		if v, b := entries[hash]; b {
+			return v
+		}
+		v := &StorageEntry{Hash: hash, Type: t}
+		entries[hash] = v
+		return v
+	}
+
+	unfins, e := model.AllUnfinished(eng.Where("hash <> ?", ""), 0)
+	if e != nil {
+		return e
+	}
+	for _, u := range *unfins {
+		v := entry(u.Hash, u.Type)
+		v.Bangumi = strings.Split(u.Relate, "@")[0]
+		v.Sharpness = u.Sharpness
+		//the recorded size of a dir may miss the sub dirs,the dirs are sized by the api
+		if u.Object != nil && u.Object.Link != nil && u.Object.Link.Type != int(iface.TDirectory) {
+			v.Size = u.Object.Link.Size
+		}
+	}
+
+	videos, e := model.AllVideos(eng.Where(""), 0)
+	if e != nil {
+		return e
+	}
+	for _, video := range *videos {
+		for t, hash := range map[model.Type]string{
+			model.TypeSlice:   video.M3U8Hash,
+			model.TypeVideo:   video.SourceHash,
+			model.TypePoster:  video.PosterHash,
+			model.TypeThumb:   video.ThumbHash,
+			model.TypePreview: video.PreviewHash,
+		} {
+			if hash == "" {
+				continue
+			}
+			v := entry(hash, t)
+			v.Bangumi = video.Bangumi
+			v.Series = video.Series
+			if v.Sharpness == "" && (t == model.TypeSlice || t == model.TypeVideo) {
+				v.Sharpness = video.Sharpness
+			}
+		}
+	}
+
+	pins, e := model.AllPin(eng.Where(""), 0)
+	if e != nil {
+		return e
+	}
+	for _, pin := range *pins {
+		if v, b := entries[pin.PinHash]; b {
+			v.Peers = append(v.Peers, pin.PeerID)
+		}
+	}
+
+	list := make([]*StorageEntry, 0, len(entries))
+	for _, v := range entries {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Hash < list[j].Hash
+	})
+	return database.PushTo(seed.StepperAPI, &storageReport{
+		report:  s.report,
+		entries: list,
+	})
+}
+
+type storageReport struct {
+	report  *StorageReport
+	entries []*StorageEntry
+}
+
+// Call ...
+func (s *storageReport) Call(a *seed.API, api *httpapi.HttpApi) error {
+	if s.report.Stat {
+		for _, v := range s.entries {
+			select {
+			case <-a.Context().Done():
+				return nil
+			default:
+			}
+			if v.Size > 0 {
+				continue
+			}
+			size, e := seed.CumulativeSize(a.Context(), api, v.Hash)
+			if e != nil {
+				log.With("hash", v.Hash).Error(e)
+				continue
+			}
+			v.Size = size
+		}
+	}
+	result := NewStorageResult(s.entries)
+	log.With("count", result.Total.Count, "size", result.Total.Size, "unknown", result.Unknown).Info("storage report")
+	if !s.report.Entries {
+		result.Entries = nil
+	}
+	if s.report.Format == ReportFormatCSV {
+		return result.WriteCSV(s.report.Output)
+	}
+	return seed.JSONWrite(s.report.Output, result)
+}
+
+// NewStorageResult aggregate the entries
+func NewStorageResult(entries []*StorageEntry) *StorageResult {
+	r := &StorageResult{
+		Types:     make(map[string]*StorageGroup),
+		Sharpness: make(map[string]*StorageGroup),
+		Series:    make(map[string]*StorageGroup),
+		Peers:     make(map[string]*StorageGroup),
+		Videos:    make(map[string]*StorageGroup),
+		Entries:   entries,
+	}
+	add := func(groups map[string]*StorageGroup, key string, size uint64) {
+		g, b := groups[key]
+		if !b {
+			g = &StorageGroup{}
+			groups[key] = g
+		}
+		g.Count++
+		g.Size += size
+	}
+	for _, v := range entries {
+		if v.Size == 0 {
+			r.Unknown++
+		}
+		r.Total.Count++
+		r.Total.Size += v.Size
+		add(r.Types, string(v.Type), v.Size)
+		add(r.Sharpness, v.Sharpness, v.Size)
+		add(r.Series, v.Series, v.Size)
+		add(r.Videos, v.Bangumi, v.Size)
+		for _, peer := range v.Peers {
+			add(r.Peers, peer, v.Size)
+		}
+	}
+	return r
+}
+
+// WriteCSV write the groups as group,key,count,size
+func (r *StorageResult) WriteCSV(path string) error {
+	file, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	records := [][]string{
+		{"group", "key", "count", "size"},
+		{"total", "", strconv.Itoa(r.Total.Count), strconv.FormatUint(r.Total.Size, 10)},
+	}
+	for _, group := range []struct {
+		name   string
+		groups map[string]*StorageGroup
+	}{
+		{"type", r.Types},
+		{"sharpness", r.Sharpness},
+		{"series", r.Series},
+		{"peer", r.Peers},
+		{"video", r.Videos},
+	} {
+		var keys []string
+		for key := range group.groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			g := group.groups[key]
+			records = append(records, []string{group.name, key, strconv.Itoa(g.Count), strconv.FormatUint(g.Size, 10)})
+		}
+	}
+	e = w.WriteAll(records)
+	if e != nil {
+		return e
+	}
+	return file.Sync()
+}
+
+var _ seed.DatabaseCaller = &storageCollect{}
+var _ seed.APICaller = &storageReport{}
@@ -0,0 +1,50 @@
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glvd/seed/model"
+)
+
+// TestStorageResult ...
+func TestStorageResult(t *testing.T) {
+	result := NewStorageResult([]*StorageEntry{
+		{Hash: "QmSlice", Type: model.TypeSlice, Bangumi: "ABP-123", Sharpness: "1080P", Series: "ABP", Size: 300, Peers: []string{"QmPeerA", "QmPeerB"}},
+		{Hash: "QmSource", Type: model.TypeVideo, Bangumi: "ABP-123", Sharpness: "1080P", Series: "ABP", Size: 700, Peers: []string{"QmPeerA"}},
+		{Hash: "QmPoster", Type: model.TypePoster, Bangumi: "SSNI-001"},
+	})
+	if result.Total.Count != 3 || result.Total.Size != 1000 || result.Unknown != 1 {
+		t.Error(result.Total, result.Unknown)
+	}
+	if g := result.Types[string(model.TypeSlice)]; g == nil || g.Size != 300 {
+		t.Error(g)
+	}
+	if g := result.Peers["QmPeerA"]; g == nil || g.Count != 2 || g.Size != 1000 {
+		t.Error(g)
+	}
+	if g := result.Videos["ABP-123"]; g == nil || g.Size != 1000 {
+		t.Error(g)
+	}
+
+	dir, e := ioutil.TempDir("", "storage")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "storage.csv")
+	e = result.WriteCSV(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(string(data), "series,ABP,2,1000") || !strings.HasPrefix(string(data), "group,key,count,size") {
+		t.Error(string(data))
+	}
+}
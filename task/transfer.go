@@ -350,6 +350,13 @@ func transferUpdate(engine *xorm.Engine) (e error) {
 		if from.Type == model.TypeSlice {
 			video.Sharpness = seed.MustString(from.Sharpness, video.Sharpness)
 			video.M3U8Hash = seed.MustString(from.Hash, video.M3U8Hash)
+			video.M3U8 = seed.MustString(from.M3U8, video.M3U8)
+			video.MPD = seed.MustString(from.MPD, video.MPD)
+			video.SliceFormat = seed.MustString(from.SliceFormat, video.SliceFormat)
+			video.Trickplay = seed.MustString(from.Trickplay, video.Trickplay)
+			if len(from.Renditions) > 0 {
+				video.Renditions = from.Renditions
+			}
 		} else if from.Type == model.TypeVideo {
 			video.Sharpness = seed.MustString(from.Sharpness, video.Sharpness)
 			video.SourceHash = seed.MustString(from.Hash, video.SourceHash)
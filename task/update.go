@@ -2,6 +2,7 @@ package task
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/glvd/seed"
 	"github.com/glvd/seed/model"
@@ -38,6 +39,7 @@ type Update struct {
 	Limit   int
 	Include []interface{}
 	Exclude []interface{}
+	Library *Library //sync the updated videos into the mfs library,nil to skip
 }
 
 // Task ...
@@ -68,6 +70,7 @@ func (u *Update) call(seeder seed.Seeder) error {
 		Limit:   u.Limit,
 		Include: u.Include,
 		Exclude: u.Exclude,
+		Library: u.Library,
 	}
 	return seeder.PushTo(seed.StepperDatabase, c)
 }
@@ -78,6 +81,7 @@ type dbUpdate struct {
 	Limit   int
 	Include []interface{}
 	Exclude []interface{}
+	Library *Library
 }
 
 // Call ...
@@ -90,6 +94,7 @@ func (u *dbUpdate) Call(database *seed.Database, eng *xorm.Engine) (e error) {
 		session = session.NotIn("bangumi", u.Exclude)
 	}
 	v := make(chan *model.Video)
+	var updated []interface{}
 
 	go func(s *xorm.Session, video chan<- *model.Video) {
 		defer s.Close()
@@ -132,11 +137,24 @@ VideoEnd:
 				e := model.AddOrUpdateVideo(eng.Where(""), newVideo)
 				if e != nil {
 					log.Error(e)
+					continue
 				}
+				//only announce the updated content
+				if newVideo.M3U8Hash != video.M3U8Hash || newVideo.SourceHash != video.SourceHash {
+					seed.AnnounceVideo(database, newVideo)
+				}
+				updated = append(updated, newVideo.Bangumi)
 			}
 		}
 	}
 	log.Info("update end")
+	if u.Library != nil && len(updated) > 0 {
+		return database.PushTo(seed.StepperAPI, &librarySync{
+			root:    u.Library.Root,
+			bangumi: updated,
+			key:     u.Library.Key,
+		})
+	}
 	return nil
 }
 
@@ -146,6 +164,20 @@ func parseTypeHash(u *model.Unfinished) func(video *model.Video) {
 		return func(video *model.Video) {
 			video.Sharpness = u.Sharpness
 			video.M3U8Hash = u.Hash
+			video.M3U8 = seed.MustString(u.M3U8, video.M3U8)
+			if u.SliceFormat == string(seed.SliceFormatDASH) {
+				video.M3U8 = ""
+			}
+			video.MPD = u.MPD
+			video.SliceFormat = u.SliceFormat
+			video.Trickplay = u.Trickplay
+			if len(u.Language) > 0 {
+				video.AudioLanguage = u.Language
+			}
+			video.Renditions = u.Renditions
+			if u.Encrypt {
+				video.Key = u.Key
+			}
 		}
 	case model.TypeVideo:
 		return func(video *model.Video) {
@@ -162,12 +194,33 @@ func parseTypeInfo(u *model.Unfinished) func(video *model.Video) {
 	switch u.Type {
 	case model.TypePoster:
 		return func(video *model.Video) {
+			//the supplied poster is kept
+			if u.Extract && video.PosterHash != "" {
+				return
+			}
 			video.PosterHash = u.Hash
 		}
 	case model.TypeThumb:
 		return func(video *model.Video) {
+			if u.Extract && video.ThumbHash != "" {
+				return
+			}
 			video.ThumbHash = u.Hash
 		}
+	case model.TypePreview:
+		return func(video *model.Video) {
+			video.PreviewHash = u.Hash
+		}
+	case model.TypeCaption:
+		return func(video *model.Video) {
+			languages := strings.Split(video.Caption, ",")
+			for _, language := range languages {
+				if language == u.Caption {
+					return
+				}
+			}
+			video.Caption = strings.Trim(video.Caption+","+u.Caption, ",")
+		}
 	}
 	return func(video *model.Video) {
 
@@ -0,0 +1,69 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/glvd/seed"
+	"github.com/glvd/seed/model"
+)
+
+// VideoPreview generate the preview clips of the videos under the path
+type VideoPreview struct {
+	Path    string
+	Output  string //the dir of the preview clips
+	Preview *seed.Preview
+}
+
+// NewVideoPreview ...
+func NewVideoPreview() *VideoPreview {
+	return &VideoPreview{
+		Path:    os.TempDir(),
+		Output:  filepath.Join(os.TempDir(), "preview"),
+		Preview: seed.DefaultPreview,
+	}
+}
+
+// CallTask ...
+func (v *VideoPreview) CallTask(seeder seed.Seeder, task *seed.Task) error {
+	select {
+	case <-seeder.Context().Done():
+		return nil
+	default:
+		pushVideoFiles(seeder, v.Path, func(file string) seed.ProcessCaller {
+			return &previewCall{
+				path:    file,
+				output:  v.Output,
+				preview: v.Preview,
+			}
+		})
+	}
+	return nil
+}
+
+// Task ...
+func (v *VideoPreview) Task() *seed.Task {
+	return seed.NewTask(v)
+}
+
+type previewCall struct {
+	path    string
+	output  string
+	preview *seed.Preview
+}
+
+// Call ...
+func (call *previewCall) Call(process *seed.Process) error {
+	u := defaultUnfinished(call.path)
+	u.Type = model.TypePreview
+	u.Relate = seed.OnlyName(call.path)
+	preview, e := call.preview.Generate(process.Context(), call.path, filepath.Join(call.output, u.Checksum), u.Relate)
+	if e != nil {
+		return e
+	}
+	//the checksum is kept as the source,one preview of every source
+	u.Name = filepath.Base(preview)
+	return process.PushTo(addFileCall(preview, u))
+}
+
+var _ seed.ProcessCaller = &previewCall{}
@@ -2,6 +2,7 @@ package task
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/glvd/seed"
 	"github.com/glvd/seed/model"
@@ -23,25 +24,31 @@ func (v *VideoSlice) CallTask(seeder seed.Seeder, task *seed.Task) error {
 	case <-seeder.Context().Done():
 		return nil
 	default:
-		files := seed.GetFiles(v.Path)
-		for _, f := range files {
-			if seed.IsVideo(f) {
-				call := &videoCall{
-					path:     f,
-					skipType: v.SkipType,
-				}
-				e := seeder.PushTo(seed.StepperProcess, call)
-				if e != nil {
-					log.Error(e)
-					continue
-				}
+		pushVideoFiles(seeder, v.Path, func(file string) seed.ProcessCaller {
+			return &videoCall{
+				path:     file,
+				skipType: v.SkipType,
 			}
-		}
+		})
 	}
 
 	return nil
 }
 
+// pushVideoFiles push the caller of every video under path to the process thread
+func pushVideoFiles(seeder seed.Seeder, path string, fn func(file string) seed.ProcessCaller) {
+	files := seed.GetFiles(path)
+	for _, f := range files {
+		if !seed.IsVideo(f) {
+			continue
+		}
+		e := seeder.PushTo(seed.StepperProcess, fn(f))
+		if e != nil {
+			log.Error(e)
+		}
+	}
+}
+
 // NewVideoSlice ...
 func NewVideoSlice() *VideoSlice {
 	path := os.TempDir()
@@ -71,43 +78,192 @@ func (call *videoCall) Call(process *seed.Process) (e error) {
 	u.Sharpness = f.Resolution() + "P"
 	u.Relate = seed.OnlyName(call.path)
 	if !seed.SkipTypeVerify(u.Type, call.skipType...) {
-		e = process.PushTo(seed.APICallback(u.Clone(), func(api *seed.API, ipapi *httpapi.HttpApi, v interface{}) (e error) {
-			u := v.(*model.Unfinished)
-			resolved, e := seed.AddFile(api, call.path)
-			if e != nil {
-				return e
-			}
-			u.Hash = model.PinHash(resolved)
-			log.With("hash", u.Hash, "sharpness", u.Sharpness).Info("video")
-			return api.PushTo(seed.DatabaseCallback(u, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
-				return model.AddOrUpdateUnfinished(eng.Where(""), v.(*model.Unfinished))
-			}))
-		}))
+		e = process.PushTo(addFileCall(call.path, u.Clone()))
 		if e != nil {
 			return e
 		}
 	}
 
+	call.extractImages(process, u, f)
+
+	captions := seed.FindCaptions(call.path)
+	captions = append(captions, seed.EmbeddedCaptions(call.path, f)...)
+	captions = call.convertCaptions(process, u, captions)
+
 	u.Type = model.TypeSlice
 	if !seed.SkipTypeVerify(u.Type, call.skipType...) {
-		e = process.PushTo(seed.SliceCall(call.path, u.Clone(), func(slice *seed.Slice, sa *cmd.SplitArgs, v interface{}) (e error) {
+		//the checksum is kept as the source,the transcoded file is sliced only
+		file, e := process.TranscodeFile(call.path, u.Checksum)
+		if e != nil {
+			return e
+		}
+		e = process.PushTo(seed.SliceCall(file, u.Clone(), func(slice *seed.Slice, sa *cmd.SplitArgs, v interface{}) (e error) {
 			u := v.(*model.Unfinished)
 			return slice.PushTo(seed.APICallback(u.Clone(), func(api *seed.API, ipapi *httpapi.HttpApi, v interface{}) (e error) {
 				u := v.(*model.Unfinished)
-				resolved, e := seed.AddDir(api, sa.Output)
+				resolved, e := seed.AddDir(api, sa.Output, seed.AddUnfinishedArg(u))
 				if e != nil {
 					return e
 				}
 				u.Hash = model.PinHash(resolved)
 				log.With("hash", u.Hash, "sharpness", u.Sharpness).Info("slice")
 				return api.PushTo(seed.DatabaseCallback(u, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
-					return model.AddOrUpdateUnfinished(eng.Where(""), v.(*model.Unfinished))
+					e = model.AddOrUpdateUnfinished(eng.Where(""), v.(*model.Unfinished))
+					if e == nil {
+						seed.AnnounceUnfinished(database, v.(*model.Unfinished))
+					}
+					return e
 				}))
 			}))
-		}))
+		}, captions...))
 		if e != nil {
 			return e
 		}
 	}
 	return nil
 }
+
+// convertCaptions convert the captions to webvtt and add them as the caption unfinished,returns the converted captions
+func (call *videoCall) convertCaptions(process *seed.Process, u *model.Unfinished, captions []*seed.Caption) []*seed.Caption {
+	if len(captions) == 0 {
+		return nil
+	}
+	dir := filepath.Join(os.TempDir(), "caption", u.Checksum)
+	e := os.MkdirAll(dir, os.ModePerm)
+	if e != nil {
+		log.Error(e)
+		return nil
+	}
+	var converted []*seed.Caption
+	for i, c := range captions {
+		e := seed.ConvertCaption(process.Context(), c, dir, u.Relate+"."+c.Name(i))
+		if e != nil {
+			log.With("file", c.File, "stream", c.Stream).Error(e)
+			continue
+		}
+		converted = append(converted, c)
+		if seed.SkipTypeVerify(model.TypeCaption, call.skipType...) {
+			continue
+		}
+		cu := defaultUnfinished(c.VTT)
+		cu.Type = model.TypeCaption
+		cu.Relate = u.Relate
+		cu.Caption = c.Language
+		e = process.PushTo(addFileCall(c.VTT, cu))
+		if e != nil {
+			log.Error(e)
+		}
+	}
+	return converted
+}
+
+// extractImages extract the poster and the thumb from the frames and add them as the unfinished,
+// the extraction is the fallback of the video without the supplied images
+func (call *videoCall) extractImages(process *seed.Process, u *model.Unfinished, format *cmd.StreamFormat) {
+	if process.Frame == nil {
+		return
+	}
+	supplied, e := suppliedImages(process, u.Relate)
+	if e != nil {
+		log.With("relate", u.Relate).Error(e)
+		return
+	}
+	extract := *process.Frame
+	if supplied[model.TypePoster] || seed.SkipTypeVerify(model.TypePoster, call.skipType...) {
+		extract.Poster = nil
+	}
+	if supplied[model.TypeThumb] || seed.SkipTypeVerify(model.TypeThumb, call.skipType...) {
+		extract.Thumb = nil
+	}
+	if extract.Poster == nil && extract.Thumb == nil {
+		return
+	}
+	dir := process.FrameDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "frame")
+	}
+	poster, thumb, e := extract.Extract(process.Context(), call.path, format, filepath.Join(dir, u.Checksum), u.Relate)
+	if e != nil {
+		log.With("file", call.path).Error(e)
+		return
+	}
+	call.addImage(process, u, model.TypePoster, poster)
+	call.addImage(process, u, model.TypeThumb, thumb)
+}
+
+// suppliedImages the image types supplied for the relate through the database thread
+func suppliedImages(process *seed.Process, relate string) (map[model.Type]bool, error) {
+	type result struct {
+		supplied map[model.Type]bool
+		e        error
+	}
+	r := make(chan result, 1)
+	e := process.PushTo(seed.DatabaseCallback(relate, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+		supplied, e := findSuppliedImages(eng, v.(string))
+		r <- result{supplied: supplied, e: e}
+		return e
+	}))
+	if e != nil {
+		return nil, e
+	}
+	select {
+	case <-process.Context().Done():
+		return nil, process.Context().Err()
+	case v := <-r:
+		return v.supplied, v.e
+	}
+}
+
+// findSuppliedImages the image types of the relate,by the video record or the added unfinished
+func findSuppliedImages(eng *xorm.Engine, relate string) (map[model.Type]bool, error) {
+	video, e := model.FindVideo(eng.Where(""), relate)
+	if e != nil {
+		return nil, e
+	}
+	supplied := map[model.Type]bool{
+		model.TypePoster: video.PosterHash != "",
+		model.TypeThumb:  video.ThumbHash != "",
+	}
+	unfins, e := model.AllUnfinished(eng.Where("relate = ?", relate).In("type", model.TypePoster, model.TypeThumb), 0)
+	if e != nil {
+		return nil, e
+	}
+	for _, unfin := range *unfins {
+		supplied[unfin.Type] = true
+	}
+	return supplied, nil
+}
+
+func (call *videoCall) addImage(process *seed.Process, u *model.Unfinished, typ model.Type, file string) {
+	if file == "" {
+		return
+	}
+	iu := defaultUnfinished(file)
+	iu.Type = typ
+	iu.Relate = u.Relate
+	iu.Extract = true
+	e := process.PushTo(addFileCall(file, iu))
+	if e != nil {
+		log.Error(e)
+	}
+}
+
+// addFileCall add the file as the unfinished through the api thread
+func addFileCall(file string, u *model.Unfinished) (seed.Stepper, seed.APICaller) {
+	return seed.APICallback(u, func(api *seed.API, ipapi *httpapi.HttpApi, v interface{}) (e error) {
+		u := v.(*model.Unfinished)
+		resolved, e := seed.AddFile(api, file, seed.AddUnfinishedArg(u))
+		if e != nil {
+			return e
+		}
+		u.Hash = model.PinHash(resolved)
+		log.With("hash", u.Hash, "sharpness", u.Sharpness, "caption", u.Caption).Info(string(u.Type))
+		return api.PushTo(seed.DatabaseCallback(u, func(database *seed.Database, eng *xorm.Engine, v interface{}) (e error) {
+			e = model.AddOrUpdateUnfinished(eng.Where(""), v.(*model.Unfinished))
+			if e == nil {
+				seed.AnnounceUnfinished(database, v.(*model.Unfinished))
+			}
+			return e
+		}))
+	})
+}
@@ -2,6 +2,8 @@ package task
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/glvd/seed"
@@ -40,3 +42,35 @@ func TestNewVideoProcess(t *testing.T) {
 	//sdb.Done()
 	//fmt.Println("db end")
 }
+
+// TestFindSuppliedImages ...
+func TestFindSuppliedImages(t *testing.T) {
+	dir, e := ioutil.TempDir("", "supplied")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+	eng := carTestEngine(t, dir, "test.db")
+	defer eng.Close()
+
+	supplied, e := findSuppliedImages(eng, "abp-123")
+	if e != nil || supplied[model.TypePoster] || supplied[model.TypeThumb] {
+		t.Fatal(supplied, e)
+	}
+	e = model.AddOrUpdateVideo(eng.Where(""), &model.Video{FindNo: "ABP123", Bangumi: "ABP-123", PosterHash: "QmPoster"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	supplied, e = findSuppliedImages(eng, "abp-123")
+	if e != nil || !supplied[model.TypePoster] || supplied[model.TypeThumb] {
+		t.Fatal(supplied, e)
+	}
+	e = model.AddOrUpdateUnfinished(eng.Where(""), &model.Unfinished{Checksum: "thumb", Type: model.TypeThumb, Relate: "abp-123"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	supplied, e = findSuppliedImages(eng, "abp-123")
+	if e != nil || !supplied[model.TypePoster] || !supplied[model.TypeThumb] {
+		t.Fatal(supplied, e)
+	}
+}
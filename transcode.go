@@ -0,0 +1,157 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+// TranscodeProfile the target of the sources with incompatible codecs
+type TranscodeProfile struct {
+	VideoCodecs  []string //the video codecs kept as is
+	AudioCodecs  []string //the audio codecs kept as is
+	Video        string   //video encoder
+	Audio        string   //audio encoder
+	Preset       string
+	CRF          int
+	AudioBitRate int64 //K
+	PixFmt       string
+	Ext          string
+}
+
+// DefaultTranscodeProfile h264/aac mp4
+var DefaultTranscodeProfile = &TranscodeProfile{
+	VideoCodecs:  []string{"h264"},
+	AudioCodecs:  []string{"aac"},
+	Video:        "libx264",
+	Audio:        "aac",
+	Preset:       "veryfast",
+	CRF:          20,
+	AudioBitRate: 192,
+	PixFmt:       "yuv420p",
+	Ext:          ".mp4",
+}
+
+// ProcessArgs ...
+type ProcessArgs func(p *Process)
+
+// ProcessTranscodeArg transcode the incompatible sources to profile under dir before slicing,
+// the transcoded files are cached by the checksum of the source
+func ProcessTranscodeArg(profile *TranscodeProfile, dir string) ProcessArgs {
+	return func(p *Process) {
+		if profile == nil {
+			profile = DefaultTranscodeProfile
+		}
+		p.Transcode = profile
+		p.TranscodeDir = dir
+	}
+}
+
+// NeedTranscode check the streams of the format,returns the video and audio need to be transcoded
+func (t *TranscodeProfile) NeedTranscode(format *cmd.StreamFormat) (video bool, audio bool) {
+	if v := format.Video(); v != nil {
+		video = !containsString(t.VideoCodecs, v.CodecName)
+	}
+	if a := format.Audio(); a != nil {
+		audio = !containsString(t.AudioCodecs, a.CodecName)
+	}
+	return
+}
+
+// Args the ffmpeg args to transcode file to output,the compatible streams are copied
+func (t *TranscodeProfile) Args(file string, output string, format *cmd.StreamFormat) []string {
+	video, audio := t.NeedTranscode(format)
+	args := []string{"-y", "-i", file, "-map", "0:v:0", "-map", "0:a?"}
+	if video {
+		args = append(args, "-c:v", t.Video)
+		if t.Preset != "" {
+			args = append(args, "-preset", t.Preset)
+		}
+		if t.CRF > 0 {
+			args = append(args, "-crf", fmt.Sprint(t.CRF))
+		}
+		if t.PixFmt != "" {
+			args = append(args, "-pix_fmt", t.PixFmt)
+		}
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+	if audio {
+		args = append(args, "-c:a", t.Audio)
+		if t.AudioBitRate > 0 {
+			args = append(args, "-b:a", fmt.Sprintf("%dK", t.AudioBitRate))
+		}
+	} else {
+		args = append(args, "-c:a", "copy")
+	}
+	return append(args, "-movflags", "+faststart", output)
+}
+
+// Output the cached output of the checksum under dir
+func (t *TranscodeProfile) Output(dir string, checksum string) string {
+	return filepath.Join(dir, checksum+t.Ext)
+}
+
+// Transcode transcode the file if the codecs are not compatible with the profile,
+// returns the file to slice,the cached output of the checksum under dir is reused
+func (t *TranscodeProfile) Transcode(ctx context.Context, file string, dir string, checksum string) (string, error) {
+	output := t.Output(dir, checksum)
+	if info, e := os.Stat(output); e == nil && info.Size() > 0 {
+		log.With("file", file, "output", output).Info("transcoded cache")
+		return output, nil
+	}
+	e := os.MkdirAll(dir, os.ModePerm)
+	if e != nil {
+		return "", e
+	}
+	b, e := t.TranscodeTo(ctx, file, output)
+	if e != nil {
+		return "", e
+	}
+	if !b {
+		return file, nil
+	}
+	return output, nil
+}
+
+// TranscodeTo transcode the file to output if the codecs are not compatible with the profile,
+// returns false when the file is compatible
+func (t *TranscodeProfile) TranscodeTo(ctx context.Context, file string, output string) (bool, error) {
+	format, e := cmd.FFProbeStreamFormat(file)
+	if e != nil {
+		return false, e
+	}
+	if format.Video() == nil {
+		return false, errors.New("video stream not found")
+	}
+	video, audio := t.NeedTranscode(format)
+	if !video && !audio {
+		return false, nil
+	}
+	//write to the temp name,the interrupted output is not left as the result
+	tmp := strings.TrimSuffix(output, t.Ext) + ".tmp" + t.Ext
+	log.With("file", file, "video", video, "audio", audio).Info("transcode")
+	e = FFMpegRun(ctx, t.Args(file, tmp, format)...)
+	if e != nil {
+		os.Remove(tmp)
+		return false, e
+	}
+	return true, os.Rename(tmp, output)
+}
+
+// TranscodeFile transcode the file with the profile of the process,the file is returned if the transcode is disabled
+func (p *Process) TranscodeFile(file string, checksum string) (string, error) {
+	if p.Transcode == nil {
+		return file, nil
+	}
+	dir := p.TranscodeDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "transcode")
+	}
+	return p.Transcode.Transcode(p.Context(), file, dir, checksum)
+}
@@ -0,0 +1,39 @@
+package seed_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/glvd/seed"
+	cmd "github.com/godcong/go-ffmpeg-cmd"
+)
+
+func streamFormat(t *testing.T, video, audio string) *cmd.StreamFormat {
+	format := new(cmd.StreamFormat)
+	e := json.Unmarshal([]byte(`{"streams":[{"codec_type":"video","codec_name":"`+video+`"},{"codec_type":"audio","codec_name":"`+audio+`"}]}`), format)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return format
+}
+
+// TestTranscodeProfile ...
+func TestTranscodeProfile(t *testing.T) {
+	profile := seed.DefaultTranscodeProfile
+	if video, audio := profile.NeedTranscode(streamFormat(t, "h264", "aac")); video || audio {
+		t.Error("h264/aac need no transcode")
+	}
+	video, audio := profile.NeedTranscode(streamFormat(t, "hevc", "ac3"))
+	if !video || !audio {
+		t.Error("hevc/ac3 need transcode")
+	}
+	args := strings.Join(profile.Args("in.mkv", "out.mp4", streamFormat(t, "vc1", "aac")), " ")
+	if !strings.Contains(args, "-c:v libx264 -preset veryfast -crf 20") || !strings.Contains(args, "-c:a copy") ||
+		!strings.HasSuffix(args, "out.mp4") {
+		t.Error(args)
+	}
+	if profile.Output("cache", "sum") != "cache/sum.mp4" {
+		t.Error(profile.Output("cache", "sum"))
+	}
+}